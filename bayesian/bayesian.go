@@ -3,10 +3,12 @@ package bayesian
 import (
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"math"
 	"os"
-	"path/filepath"
+	"sync"
 	"sync/atomic"
 )
 
@@ -14,8 +16,36 @@ import (
 // we have not seen before appears in the class.
 const defaultProb = 0.00000000001
 
-// ErrUnderflow is returned when an underflow is detected.
-var ErrUnderflow = errors.New("possible underflow detected")
+var (
+	// ErrUnderflow is returned when an underflow is detected.
+	ErrUnderflow = errors.New("possible underflow detected")
+
+	// ErrTooFewClasses is returned when a classifier is created
+	// with less than two classes.
+	ErrTooFewClasses = errors.New("provide at least two classes")
+
+	// ErrDuplicateClass is returned when a classifier is created
+	// with the same class twice.
+	ErrDuplicateClass = errors.New("classes must be unique")
+
+	// ErrUnknownModel is returned when a classifier is created
+	// with a Model that does not exist.
+	ErrUnknownModel = errors.New("unknown model")
+
+	// ErrUnknownClass is returned when a class the classifier
+	// was not created with is used.
+	ErrUnknownClass = errors.New("unknown class")
+
+	// ErrNotConverted is returned when a TF-IDF classifier is
+	// used before ConvertTermsFreqToTfIdf was called.
+	ErrNotConverted = errors.New("TF-IDF classifier not converted, call ConvertTermsFreqToTfIdf first")
+
+	// ErrLegacyTfIdf is returned when a TF-IDF classifier converted
+	// before the raw TF samples were kept is trained or converted
+	// again. Its samples and word counts were replaced by TF-IDF
+	// weights, so it can only classify: relearn it from scratch.
+	ErrLegacyTfIdf = errors.New("TF-IDF classifier converted by an older version can't learn, relearn it from scratch")
+)
 
 // Class defines a class that the classifier will filter:
 // C = {C_1, ..., C_n}. You should define your classes as a
@@ -38,6 +68,17 @@ type Classifier struct {
 	tfIdf           bool
 	DidConvertTfIdf bool // we can't classify a TF-IDF classifier if we haven't yet
 	// called ConverTermsFreqToTfIdf
+	model   Model
+	buckets uint64 // feature hashing buckets, 0 when disabled
+	priors  PriorSource
+	weights map[Class]float64          // prior weights for PriorWeights
+	decay   float64                    // decay factor applied by every Learn, 0 when disabled
+	unit    float64                    // weight of a new observation, 0 meaning 1, see Decay
+	cache   atomic.Pointer[modelCache] // derived model statistics, nil when stale
+
+	tfIdfStale  atomic.Bool // documents were learned since the last TF-IDF conversion
+	tfIdfMu     sync.Mutex  // serialises the lazy TF-IDF conversion
+	legacyTfIdf bool        // converted in place by an older version, see ErrLegacyTfIdf
 }
 
 // serializableClassifier represents a container for
@@ -50,6 +91,13 @@ type serializableClassifier struct {
 	Datas           map[Class]*classData
 	TfIdf           bool
 	DidConvertTfIdf bool
+	Model           Model
+	Buckets         uint64
+	Priors          PriorSource
+	PriorWeights    map[Class]float64
+	Decay           float64
+	Unit            float64
+	LegacyTfIdf     bool
 }
 
 // classData holds the frequency data for words in a
@@ -57,27 +105,60 @@ type serializableClassifier struct {
 // structure with a trie-like structure for more
 // efficient storage.
 type classData struct {
-	Freqs   map[string]float64
-	FreqTfs map[string][]float64
-	Total   int
+	Freqs    map[string]float64
+	FreqTfs  map[string][]float64 // raw TF samples of every document
+	TfIdfs   map[string]float64   // TF-IDF weights, nil until converted
+	DocFreqs map[string]float64   // documents containing each word, Bernoulli only
+	Total    int
+	Docs     int
+	Mass     float64 // weight of the words, Total scaled by the decay
+	DocMass  float64 // weight of the documents, Docs scaled by the decay
 }
 
 // newClassData creates a new empty classData node.
 func newClassData() *classData {
-	return &classData{
-		Freqs:   make(map[string]float64),
-		FreqTfs: make(map[string][]float64),
+	d := new(classData)
+	d.init()
+	return d
+}
+
+// init allocates the maps that gob leaves nil when
+// they were empty.
+func (d *classData) init() {
+	if d.Freqs == nil {
+		d.Freqs = make(map[string]float64)
+	}
+	if d.FreqTfs == nil {
+		d.FreqTfs = make(map[string][]float64)
+	}
+	if d.DocFreqs == nil {
+		d.DocFreqs = make(map[string]float64)
+	}
+	if d.Mass == 0 {
+		// saved before decay was supported
+		d.Mass = float64(d.Total)
+		d.DocMass = float64(d.Docs)
 	}
 }
 
 // getWordProb returns P(W|C_j) -- the probability of seeing
 // a particular word W in a document of this class.
 func (d *classData) getWordProb(word string) float64 {
-	value, ok := d.Freqs[word]
+	value, ok := d.weights()[word]
 	if !ok {
 		return defaultProb
 	}
-	return float64(value) / float64(d.Total)
+	return float64(value) / d.Mass
+}
+
+// weights returns the word weights used to calculate
+// probabilities: the TF-IDF weights once they have been
+// computed, the raw counts otherwise.
+func (d *classData) weights() map[string]float64 {
+	if d.TfIdfs != nil {
+		return d.TfIdfs
+	}
+	return d.Freqs
 }
 
 // getWordsProb returns P(D|C_j) -- the probability of seeing
@@ -98,30 +179,47 @@ func (d *classData) getWordsProb(words []string) (prob float64) {
 // should be at least 2 in number and unique, or this method will
 // panic.
 func NewClassifierTfIdf(classes ...Class) (c *Classifier) {
-	n := len(classes)
-
-	// check size
-	if n < 2 {
-		panic("provide at least two classes")
+	c, err := NewClassifierTfIdfE(classes...)
+	if err != nil {
+		panic(err)
 	}
+	return
+}
 
-	// check uniqueness
-	check := make(map[Class]bool, n)
-	for _, class := range classes {
-		check[class] = true
+// NewClassifierTfIdfE works the same as NewClassifierTfIdf, but
+// returns ErrTooFewClasses or ErrDuplicateClass instead of
+// panicking.
+func NewClassifierTfIdfE(classes ...Class) (c *Classifier, err error) {
+	c, err = NewClassifierE(classes...)
+	if err != nil {
+		return nil, err
 	}
-	if len(check) != n {
-		panic("classes must be unique")
+	c.tfIdf = true
+	return
+}
+
+// NewClassifierModel returns a new classifier scoring documents
+// with the given model. The classes provided should be at least
+// 2 in number and unique, or this method will panic.
+func NewClassifierModel(model Model, classes ...Class) (c *Classifier) {
+	c, err := NewClassifierModelE(model, classes...)
+	if err != nil {
+		panic(err)
 	}
-	// create the classifier
-	c = &Classifier{
-		Classes: classes,
-		datas:   make(map[Class]*classData, n),
-		tfIdf:   true,
+	return
+}
+
+// NewClassifierModelE works the same as NewClassifierModel, but
+// returns an error instead of panicking.
+func NewClassifierModelE(model Model, classes ...Class) (c *Classifier, err error) {
+	if model < Multinomial || model > Complement {
+		return nil, ErrUnknownModel
 	}
-	for _, class := range classes {
-		c.datas[class] = newClassData()
+	c, err = NewClassifierE(classes...)
+	if err != nil {
+		return nil, err
 	}
+	c.model = model
 	return
 }
 
@@ -129,11 +227,21 @@ func NewClassifierTfIdf(classes ...Class) (c *Classifier) {
 // should be at least 2 in number and unique, or this method will
 // panic.
 func NewClassifier(classes ...Class) (c *Classifier) {
+	c, err := NewClassifierE(classes...)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+// NewClassifierE works the same as NewClassifier, but returns
+// ErrTooFewClasses or ErrDuplicateClass instead of panicking.
+func NewClassifierE(classes ...Class) (c *Classifier, err error) {
 	n := len(classes)
 
 	// check size
 	if n < 2 {
-		panic("provide at least two classes")
+		return nil, ErrTooFewClasses
 	}
 
 	// check uniqueness
@@ -142,7 +250,7 @@ func NewClassifier(classes ...Class) (c *Classifier) {
 		check[class] = true
 	}
 	if len(check) != n {
-		panic("classes must be unique")
+		return nil, ErrDuplicateClass
 	}
 	// create the classifier
 	c = &Classifier{
@@ -157,6 +265,12 @@ func NewClassifier(classes ...Class) (c *Classifier) {
 	return
 }
 
+// unknownClass returns the error for a class the classifier
+// does not know.
+func unknownClass(class Class) error {
+	return fmt.Errorf("%w %q", ErrUnknownClass, class)
+}
+
 // NewClassifierFromFile loads an existing classifier from
 // file. The classifier was previously saved with a call
 // to c.WriteToFile(string).
@@ -176,26 +290,94 @@ func NewClassifierFromReader(r io.Reader) (c *Classifier, err error) {
 	w := new(serializableClassifier)
 	err = dec.Decode(w)
 
-	return &Classifier{w.Classes, w.Learned, int32(w.Seen), w.Datas, w.TfIdf, w.DidConvertTfIdf}, err
+	if err != nil {
+		return nil, err
+	}
+	return w.classifier(), nil
+}
+
+// classifier builds the classifier described by w.
+func (w *serializableClassifier) classifier() *Classifier {
+	if w.Datas == nil {
+		w.Datas = make(map[Class]*classData, len(w.Classes))
+	}
+	for _, class := range w.Classes {
+		if w.Datas[class] == nil {
+			w.Datas[class] = newClassData()
+		}
+	}
+	legacy := w.LegacyTfIdf
+	for _, data := range w.Datas {
+		data.init()
+		if w.TfIdf && w.DidConvertTfIdf && data.TfIdfs == nil && len(data.Freqs) > 0 {
+			legacy = true
+		}
+	}
+	if legacy {
+		// Classifiers converted before the raw TF samples were
+		// kept apart have their weights in Freqs, and TF-IDF
+		// values in place of the samples.
+		for _, data := range w.Datas {
+			if data.TfIdfs == nil {
+				data.TfIdfs = maps.Clone(data.Freqs)
+			}
+		}
+	}
+
+	return &Classifier{
+		Classes:         w.Classes,
+		learned:         w.Learned,
+		seen:            int32(w.Seen),
+		datas:           w.Datas,
+		tfIdf:           w.TfIdf,
+		DidConvertTfIdf: w.DidConvertTfIdf,
+		model:           w.Model,
+		buckets:         w.Buckets,
+		priors:          w.Priors,
+		weights:         w.PriorWeights,
+		decay:           w.Decay,
+		unit:            w.Unit,
+		legacyTfIdf:     legacy,
+	}
 }
 
 // getPriors returns the prior probabilities for the
-// classes provided -- P(C_j).
+// classes provided -- P(C_j), derived as chosen with
+// SetPriorSource.
 //
 // TODO: There is a way to smooth priors, currently
 // not implemented here.
 func (c *Classifier) getPriors() (priors []float64) {
 	n := len(c.Classes)
 	priors = make([]float64, n, n)
-	sum := 0
+	source := c.priors
+	if source == PriorModel {
+		source = PriorWords
+		if c.model == Bernoulli {
+			source = PriorDocuments
+		}
+	}
+	if source == PriorDocuments && !c.hasDocumentCounts() {
+		source = PriorWords
+	}
+	sum := float64(0)
 	for index, class := range c.Classes {
-		total := c.datas[class].Total
-		priors[index] = float64(total)
-		sum += total
+		data := c.datas[class]
+		switch source {
+		case PriorDocuments:
+			priors[index] = data.DocMass
+		case PriorUniform:
+			priors[index] = 1
+		case PriorWeights:
+			priors[index] = c.weights[class]
+		default:
+			priors[index] = data.Mass
+		}
+		sum += priors[index]
 	}
 	if sum != 0 {
 		for i := 0; i < n; i++ {
-			priors[i] /= float64(sum)
+			priors[i] /= sum
 		}
 	}
 	return
@@ -218,6 +400,11 @@ func (c *Classifier) IsTfIdf() bool {
 	return c.tfIdf
 }
 
+// Model returns the model used to score documents.
+func (c *Classifier) Model() Model {
+	return c.model
+}
+
 // WordCount returns the number of words counted for
 // each class in the lifetime of the classifier.
 func (c *Classifier) WordCount() (result []int) {
@@ -231,22 +418,60 @@ func (c *Classifier) WordCount() (result []int) {
 
 // Observe should be used when word-frequencies have been already been learned
 // externally (e.g., hadoop)
+//
+// Observed frequencies carry no document information and
+// therefore do not feed the Bernoulli model.
 func (c *Classifier) Observe(word string, count int, which Class) {
-	data := c.datas[which]
-	data.Freqs[word] += float64(count)
+	if err := c.ObserveE(word, count, which); err != nil {
+		panic(err)
+	}
+}
+
+// ObserveE works the same as Observe, but returns
+// ErrUnknownClass or ErrLegacyTfIdf instead of panicking.
+func (c *Classifier) ObserveE(word string, count int, which Class) error {
+	data, ok := c.datas[which]
+	if !ok {
+		return unknownClass(which)
+	}
+	if c.legacyTfIdf {
+		return ErrLegacyTfIdf
+	}
+	word = c.feature(word)
+	weight := float64(count) * c.unitWeight()
+	data.Freqs[word] += weight
 	data.Total += count
+	data.Mass += weight
+	c.cache.Store(nil)
+	return nil
 }
 
 // Learn will accept new training documents for
 // supervised learning.
 func (c *Classifier) Learn(document []string, which Class) {
+	if err := c.LearnE(document, which); err != nil {
+		panic(err)
+	}
+}
+
+// LearnE works the same as Learn, but returns ErrUnknownClass
+// or ErrLegacyTfIdf instead of panicking.
+func (c *Classifier) LearnE(document []string, which Class) error {
+	if _, ok := c.datas[which]; !ok {
+		return unknownClass(which)
+	}
+	if c.legacyTfIdf {
+		return ErrLegacyTfIdf
+	}
+	document = c.features(document)
 
 	// If we are a tfidf classifier we first need to get terms as
 	// terms frequency and store that to work out the idf part later
-	// in ConvertToIDF().
+	// in ConvertToIDF(). Once converted, the TF-IDF weights are
+	// recomputed on the next classification.
 	if c.tfIdf {
 		if c.DidConvertTfIdf {
-			panic("Cannot call ConvertTermsFreqToTfIdf more than once. Reset and relearn to reconvert.")
+			c.tfIdfStale.Store(true)
 		}
 
 		// Term Frequency: word count in document / document length
@@ -265,44 +490,94 @@ func (c *Classifier) Learn(document []string, which Class) {
 
 	}
 
+	if c.decay != 0 {
+		c.age(c.decay)
+	}
+	weight := c.unitWeight()
 	data := c.datas[which]
 	for _, word := range document {
-		data.Freqs[word]++
+		data.Freqs[word] += weight
 		data.Total++
+		data.Mass += weight
+	}
+	if c.model == Bernoulli {
+		present := make(map[string]struct{}, len(document))
+		for _, word := range document {
+			present[word] = struct{}{}
+		}
+		for word := range present {
+			data.DocFreqs[word]++
+		}
 	}
+	data.Docs++
+	data.DocMass += weight
 	c.learned++
+	c.cache.Store(nil)
+	return nil
 }
 
 // ConvertTermsFreqToTfIdf uses all the TF samples for the class and converts
 // them to TF-IDF https://en.wikipedia.org/wiki/Tf%E2%80%93idf
 // once we have finished learning all the classes and have the totals.
+//
+// The raw TF samples and word counts are kept, so the classifier
+// can keep learning after the conversion: the weights are then
+// recomputed on the next classification. Calling this method again
+// recomputes them right away.
+//
+// It panics with ErrLegacyTfIdf on a classifier converted by an
+// older version, whose TF samples were already transformed.
 func (c *Classifier) ConvertTermsFreqToTfIdf() {
-
-	if c.DidConvertTfIdf {
-		panic("Cannot call ConvertTermsFreqToTfIdf more than once. Reset and relearn to reconvert.")
+	if err := c.ConvertTermsFreqToTfIdfE(); err != nil {
+		panic(err)
 	}
+}
 
-	for className := range c.datas {
+// ConvertTermsFreqToTfIdfE works the same as
+// ConvertTermsFreqToTfIdf, but returns ErrLegacyTfIdf instead of
+// panicking.
+func (c *Classifier) ConvertTermsFreqToTfIdfE() error {
+	if c.legacyTfIdf {
+		return ErrLegacyTfIdf
+	}
+	c.tfIdfMu.Lock()
+	defer c.tfIdfMu.Unlock()
+	c.convertTfIdf()
+	c.DidConvertTfIdf = true
+	return nil
+}
 
-		for wIndex := range c.datas[className].FreqTfs {
+// convertTfIdf computes the TF-IDF weights from the raw TF
+// samples. The caller must hold tfIdfMu.
+func (c *Classifier) convertTfIdf() {
+	for _, data := range c.datas {
+		idf := math.Log1p(float64(c.learned) / float64(data.Total))
+		tfIdfs := make(map[string]float64, len(data.FreqTfs))
+		for word, samples := range data.FreqTfs {
 			tfIdfAdder := float64(0)
-
-			for tfSampleIndex := range c.datas[className].FreqTfs[wIndex] {
-
+			for _, tf := range samples {
 				// we always want a possitive TF-IDF score.
-				tf := c.datas[className].FreqTfs[wIndex][tfSampleIndex]
-				c.datas[className].FreqTfs[wIndex][tfSampleIndex] = math.Log1p(tf) * math.Log1p(float64(c.learned)/float64(c.datas[className].Total))
-				tfIdfAdder += c.datas[className].FreqTfs[wIndex][tfSampleIndex]
+				tfIdfAdder += math.Log1p(tf) * idf
 			}
-			// convert the 'counts' to TF-IDF's
-			c.datas[className].Freqs[wIndex] = tfIdfAdder
+			tfIdfs[word] = tfIdfAdder
 		}
-
+		data.TfIdfs = tfIdfs
 	}
 
-	// sanity check
-	c.DidConvertTfIdf = true
+	c.tfIdfStale.Store(false)
+	c.cache.Store(nil)
+}
 
+// refreshTfIdf recomputes stale TF-IDF weights.
+func (c *Classifier) refreshTfIdf() {
+	if !c.tfIdfStale.Load() {
+		return
+	}
+	c.tfIdfMu.Lock()
+	defer c.tfIdfMu.Unlock()
+	if c.tfIdfStale.Load() {
+		c.convertTfIdf()
+	}
 }
 
 // LogScores produces "log-likelihood"-like scores that can
@@ -325,8 +600,52 @@ func (c *Classifier) ConvertTermsFreqToTfIdf() {
 // Unlike c.Probabilities(), this function is not prone to
 // floating point underflow and is relatively safe to use.
 func (c *Classifier) LogScores(document []string) (scores []float64, inx int, strict bool) {
-	if c.tfIdf && !c.DidConvertTfIdf {
-		panic("Using a TF-IDF classifier. Please call ConvertTermsFreqToTfIdf before calling LogScores.")
+	scores, inx, strict, err := c.LogScoresE(document)
+	if err != nil {
+		panic(err)
+	}
+	return scores, inx, strict
+}
+
+// LogScoresE works the same as LogScores, but returns
+// ErrNotConverted instead of panicking.
+func (c *Classifier) LogScoresE(document []string) (scores []float64, inx int, strict bool, err error) {
+	if err = c.checkConverted(); err != nil {
+		return nil, 0, false, err
+	}
+
+	scores = c.logScores(c.features(document))
+	inx, strict = findMax(scores)
+	atomic.AddInt32(&c.seen, 1)
+	return scores, inx, strict, nil
+}
+
+// checkConverted makes sure the weights of a TF-IDF
+// classifier are ready to classify documents.
+func (c *Classifier) checkConverted() error {
+	if !c.tfIdf {
+		return nil
+	}
+	// DidConvertTfIdf is only written under tfIdfMu
+	c.tfIdfMu.Lock()
+	defer c.tfIdfMu.Unlock()
+	if !c.DidConvertTfIdf {
+		return ErrNotConverted
+	}
+	if c.tfIdfStale.Load() {
+		c.convertTfIdf()
+	}
+	return nil
+}
+
+// logScores calculates the log scores of the document for
+// every class with the model of the classifier.
+func (c *Classifier) logScores(document []string) (scores []float64) {
+	switch c.model {
+	case Bernoulli:
+		return c.bernoulliLogScores(document)
+	case Complement:
+		return c.complementLogScores(document)
 	}
 
 	n := len(c.Classes)
@@ -344,9 +663,7 @@ func (c *Classifier) LogScores(document []string) (scores []float64, inx int, st
 		}
 		scores[index] = score
 	}
-	inx, strict = findMax(scores)
-	atomic.AddInt32(&c.seen, 1)
-	return scores, inx, strict
+	return scores
 }
 
 // ProbScores works the same as LogScores, but delivers
@@ -359,9 +676,29 @@ func (c *Classifier) LogScores(document []string) (scores []float64, inx int, st
 // never seen before. Depending on the application, this
 // may or may not be a concern. Consider using SafeProbScores()
 // instead.
+//
+// The Bernoulli and Complement models normalise their log
+// scores and are therefore not prone to underflow.
 func (c *Classifier) ProbScores(doc []string) (scores []float64, inx int, strict bool) {
-	if c.tfIdf && !c.DidConvertTfIdf {
-		panic("Using a TF-IDF classifier. Please call ConvertTermsFreqToTfIdf before calling ProbScores.")
+	scores, inx, strict, err := c.ProbScoresE(doc)
+	if err != nil {
+		panic(err)
+	}
+	return scores, inx, strict
+}
+
+// ProbScoresE works the same as ProbScores, but returns
+// ErrNotConverted instead of panicking.
+func (c *Classifier) ProbScoresE(doc []string) (scores []float64, inx int, strict bool, err error) {
+	if err = c.checkConverted(); err != nil {
+		return nil, 0, false, err
+	}
+	doc = c.features(doc)
+	if c.model != Multinomial {
+		scores = softmax(c.logScores(doc))
+		inx, strict = findMax(scores)
+		atomic.AddInt32(&c.seen, 1)
+		return scores, inx, strict, nil
 	}
 	n := len(c.Classes)
 	scores = make([]float64, n, n)
@@ -384,7 +721,7 @@ func (c *Classifier) ProbScores(doc []string) (scores []float64, inx int, strict
 	}
 	inx, strict = findMax(scores)
 	atomic.AddInt32(&c.seen, 1)
-	return scores, inx, strict
+	return scores, inx, strict, nil
 }
 
 // SafeProbScores works the same as ProbScores, but is
@@ -398,10 +735,17 @@ func (c *Classifier) ProbScores(doc []string) (scores []float64, inx int, strict
 //
 // Underflow detection is more costly because it also
 // has to make additional log score calculations.
+//
+// A TF-IDF classifier that was not converted yet returns
+// ErrNotConverted.
 func (c *Classifier) SafeProbScores(doc []string) (scores []float64, inx int, strict bool, err error) {
-	if c.tfIdf && !c.DidConvertTfIdf {
-		panic("Using a TF-IDF classifier. Please call ConvertTermsFreqToTfIdf before calling SafeProbScores.")
+	if c.model != Multinomial {
+		return c.ProbScoresE(doc)
+	}
+	if err = c.checkConverted(); err != nil {
+		return nil, 0, false, err
 	}
+	doc = c.features(doc)
 
 	n := len(c.Classes)
 	scores = make([]float64, n, n)
@@ -449,6 +793,8 @@ func (c *Classifier) SafeProbScores(doc []string) (scores []float64, inx int, st
 // then the expression freq[i][j] represents the frequency of the j-th
 // word within the i-th class.
 func (c *Classifier) WordFrequencies(words []string) (freqMatrix [][]float64) {
+	c.refreshTfIdf()
+	words = c.features(words)
 	n, l := len(c.Classes), len(words)
 	freqMatrix = make([][]float64, n)
 	for i := range freqMatrix {
@@ -463,75 +809,127 @@ func (c *Classifier) WordFrequencies(words []string) (freqMatrix [][]float64) {
 }
 
 // WordsByClass returns a map of words and their probability of
-// appearing in the given class.
+// appearing in the given class. With feature hashing enabled the
+// keys are buckets rather than words.
 func (c *Classifier) WordsByClass(class Class) (freqMap map[string]float64) {
+	c.refreshTfIdf()
 	freqMap = make(map[string]float64)
-	for word, cnt := range c.datas[class].Freqs {
-		freqMap[word] = float64(cnt) / float64(c.datas[class].Total)
+	if _, ok := c.datas[class]; !ok {
+		return freqMap
+	}
+	for word, cnt := range c.datas[class].weights() {
+		freqMap[word] = float64(cnt) / c.datas[class].Mass
 	}
 
 	return freqMap
 }
 
-// WriteToFile serializes this classifier to a file.
+// WriteToFile serializes this classifier to a file. The file is
+// replaced atomically, so readers never see a partial classifier.
 func (c *Classifier) WriteToFile(name string) (err error) {
-	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
+	return writeFileAtomic(name, func(w io.Writer) error {
+		_, err := c.WriteTo(w)
 		return err
-	}
-	defer file.Close()
-
-	return c.WriteTo(file)
+	})
 }
 
-// WriteClassesToFile writes all classes to files.
+// WriteClassesToFile writes all classes to files, one per class
+// named after it. It stops at the first class that can't be
+// written. See WriteToDir to also save the settings of the
+// classifier.
 func (c *Classifier) WriteClassesToFile(rootPath string) (err error) {
-	for name := range c.datas {
-		c.WriteClassToFile(name, rootPath)
+	for _, name := range c.Classes {
+		if err = c.WriteClassToFile(name, rootPath); err != nil {
+			return err
+		}
 	}
 	return
 }
 
-// WriteClassToFile writes a single class to file.
+// WriteClassToFile writes a single class to file. The file is
+// replaced atomically.
 func (c *Classifier) WriteClassToFile(name Class, rootPath string) (err error) {
-	data := c.datas[name]
-	fileName := filepath.Join(rootPath, string(name))
-	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE, 0644)
+	data, ok := c.datas[name]
+	if !ok {
+		return unknownClass(name)
+	}
+	fileName, err := classFileName(rootPath, name)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	enc := gob.NewEncoder(file)
-	err = enc.Encode(data)
-	return
+	c.refreshTfIdf()
+	if unit := c.unitWeight(); unit != 1 {
+		// class files hold the current weights
+		data = data.scaled(1 / unit)
+	}
+	return writeFileAtomic(fileName, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(data)
+	})
 }
 
 // WriteTo serializes this classifier to GOB and write to Writer.
-func (c *Classifier) WriteTo(w io.Writer) (err error) {
-	enc := gob.NewEncoder(w)
-	err = enc.Encode(&serializableClassifier{c.Classes, c.learned, int(c.seen), c.datas, c.tfIdf, c.DidConvertTfIdf})
+// It implements io.WriterTo and returns the number of bytes written.
+func (c *Classifier) WriteTo(w io.Writer) (n int64, err error) {
+	c.refreshTfIdf()
+	cw := &countingWriter{w: w}
+	enc := gob.NewEncoder(cw)
+	err = enc.Encode(&serializableClassifier{
+		Classes:         c.Classes,
+		Learned:         c.learned,
+		Seen:            int(c.seen),
+		Datas:           c.datas,
+		TfIdf:           c.tfIdf,
+		DidConvertTfIdf: c.DidConvertTfIdf,
+		Model:           c.model,
+		Buckets:         c.buckets,
+		Priors:          c.priors,
+		PriorWeights:    c.weights,
+		Decay:           c.decay,
+		Unit:            c.unit,
+		LegacyTfIdf:     c.legacyTfIdf,
+	})
+
+	return cw.n, err
+}
 
-	return
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
 }
 
 // ReadClassFromFile loads existing class data from a
-// file.
+// file, replacing what the class had learned. It returns
+// ErrLegacyTfIdf on a TF-IDF classifier converted by an older
+// version.
 func (c *Classifier) ReadClassFromFile(class Class, location string) (err error) {
-	fileName := filepath.Join(location, string(class))
-	file, err := os.Open(fileName)
-
+	old, ok := c.datas[class]
+	if !ok {
+		return unknownClass(class)
+	}
+	if c.legacyTfIdf {
+		return ErrLegacyTfIdf
+	}
+	w, err := readClassFile(location, class)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	dec := gob.NewDecoder(file)
-	w := new(classData)
-	err = dec.Decode(w)
-
-	c.learned++
+	if unit := c.unitWeight(); unit != 1 {
+		w = w.scaled(unit)
+	}
+	c.learned += w.Docs - old.Docs
 	c.datas[class] = w
+	if c.tfIdf && c.DidConvertTfIdf {
+		c.tfIdfStale.Store(true)
+	}
+	c.cache.Store(nil)
 	return
 }
 
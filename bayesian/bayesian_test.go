@@ -1,8 +1,14 @@
 package bayesian
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -13,7 +19,7 @@ const (
 
 func Assert(t *testing.T, condition bool, args ...interface{}) {
 	if !condition {
-		t.Fatal(args)
+		t.Fatal(args...)
 	}
 }
 
@@ -298,23 +304,26 @@ func TestTfIdClassifier_ConvertToTfIdf(t *testing.T) {
 
 	// Now we convert the TF's to Tf/Idf
 	// We can only this after we have learned all the documents and classes.
-	// We can add more learning afterwards but need to call ConvertToTfIdf() again before
-	// we can predict classes.
+	// We can add more learning afterwards, the weights are then
+	// recomputed before we predict classes.
 	c.ConvertTermsFreqToTfIdf()
 
 	data := c.datas[Good]
 
 	// Tf-Idf after we have converted the tf's
-	Assert(t, data.Freqs["tall"] == float64(0.5620939930012151))
-	Assert(t, data.Freqs["blonde"] == float64(0.16440195389316542))
-	Assert(t, data.Freqs["notseen"] == float64(0))
-	Assert(t, data.FreqTfs["tall"][0] == float64(0.11664504260744213))
-	Assert(t, data.FreqTfs["tall"][1] == float64(0.16440195389316542))
-	Assert(t, data.FreqTfs["tall"][2] == float64(0.28104699650060755))
+	Assert(t, data.TfIdfs["tall"] == float64(0.5620939930012151))
+	Assert(t, data.TfIdfs["blonde"] == float64(0.16440195389316542))
+	Assert(t, data.TfIdfs["notseen"] == float64(0))
+
+	// The raw counts and TF samples are kept
+	Assert(t, data.Freqs["tall"] == 3)
+	Assert(t, data.FreqTfs["tall"][0] == float64(0.3333333333333333))
+	Assert(t, data.FreqTfs["tall"][1] == float64(0.5))
+	Assert(t, data.FreqTfs["tall"][2] == float64(1))
 
 }
 
-func TestTfIdClassifier_CheckForDoubleConvert(t *testing.T) {
+func TestTfIdClassifier_Reconvert(t *testing.T) {
 
 	c := NewClassifierTfIdf(Good, Bad)
 	Assert(t, c.IsTfIdf() == true)
@@ -323,17 +332,121 @@ func TestTfIdClassifier_CheckForDoubleConvert(t *testing.T) {
 	c.Learn([]string{"tall", "blonde"}, Good)
 	c.Learn([]string{"tall"}, Good)
 
-	// We can only call ConverToTdfIdf once per learning cycle (cumulative counts).
+	// Converting again recomputes the same weights from the raw samples.
+	c.ConvertTermsFreqToTfIdf()
 	c.ConvertTermsFreqToTfIdf()
+	Assert(t, c.datas[Good].TfIdfs["tall"] == float64(0.5620939930012151))
 
-	defer func() {
-		if err := recover(); err != nil {
-			// we are good
-		}
-	}()
+}
+
+func TestTfIdClassifier_LearnAfterConvert(t *testing.T) {
+	docs := [][]string{
+		{"tall", "handsome", "rich"},
+		{"tall", "blonde"},
+		{"tall"},
+	}
+	c := NewClassifierTfIdf(Good, Bad)
+	c.Learn(docs[0], Good)
+	c.Learn([]string{"fat"}, Bad)
+	c.ConvertTermsFreqToTfIdf()
+	before, _, _ := c.LogScores([]string{"the", "tall", "man"})
+
+	// keep learning, the weights are recomputed lazily
+	c.Learn(docs[1], Good)
+	c.Learn(docs[2], Good)
+	c.Learn([]string{"short", "poor"}, Bad)
+	after, _, _ := c.LogScores([]string{"the", "tall", "man"})
+	Assert(t, before[0] != after[0], "weights not recomputed")
+
+	// same result as learning everything before converting
+	d := NewClassifierTfIdf(Good, Bad)
+	d.Learn(docs[0], Good)
+	d.Learn([]string{"fat"}, Bad)
+	d.Learn(docs[1], Good)
+	d.Learn(docs[2], Good)
+	d.Learn([]string{"short", "poor"}, Bad)
+	d.ConvertTermsFreqToTfIdf()
+	want, _, _ := d.LogScores([]string{"the", "tall", "man"})
+	Assert(t, math.Abs(after[0]-want[0]) < 1e-9 && math.Abs(after[1]-want[1]) < 1e-9, after, want)
+	Assert(t, c.datas[Good].Freqs["tall"] == 3, "raw counts")
+}
+
+// testdata/legacy_tfidf.ser was written by a version that
+// converted the TF samples in place: good learned "tall handsome
+// rich" and "tall kind", bad learned "bald poor ugly".
+func TestTfIdClassifier_Legacy(t *testing.T) {
+	doc := []string{"tall", "poor"}
+	// as scored by the version that wrote it
+	want := []float64{-28.52940534770441, -29.020289808903243}
+
+	c, err := NewClassifierFromFile("testdata/legacy_tfidf.ser")
+	Assert(t, err == nil, err)
+	scores, _, _ := c.LogScores(doc)
+	Assert(t, approx(scores[0], want[0]) && approx(scores[1], want[1]), scores, want)
+
+	Assert(t, c.LearnE([]string{"tall", "blonde"}, Good) == ErrLegacyTfIdf)
+	Assert(t, c.ObserveE("tall", 1, Good) == ErrLegacyTfIdf)
+	Assert(t, c.ConvertTermsFreqToTfIdfE() == ErrLegacyTfIdf)
+	Assert(t, c.Merge(NewClassifierTfIdf(Good, Bad)) == ErrLegacyTfIdf)
+	Assert(t, NewClassifierTfIdf(Good, Bad).Merge(c) == ErrLegacyTfIdf)
+	_, err = c.LearnFrom(context.Background(), strings.NewReader("good\ttall blonde\n"), TSV, nil)
+	Assert(t, err == ErrLegacyTfIdf, err)
+	Assert(t, c.Learned() == 3, "learned")
+	scores, _, _ = c.LogScores(doc)
+	Assert(t, approx(scores[0], want[0]) && approx(scores[1], want[1]), "changed:", scores, want)
+
+	// the weights are not shared with the word counts
+	delete(c.datas[Good].Freqs, "tall")
+	Assert(t, c.datas[Good].TfIdfs["tall"] > 0, "weights aliased")
+
+	// still refused once saved again
+	var buf bytes.Buffer
+	_, err = c.WriteTo(&buf)
+	Assert(t, err == nil, err)
+	d, err := NewClassifierFromReader(&buf)
+	Assert(t, err == nil, err)
+	Assert(t, d.LearnE([]string{"tall"}, Good) == ErrLegacyTfIdf)
+	dir := t.TempDir()
+	Assert(t, c.WriteToDir(dir) == nil)
+	d, err = NewClassifierFromDir(dir)
+	Assert(t, err == nil, err)
+	Assert(t, d.LearnE([]string{"tall"}, Good) == ErrLegacyTfIdf)
+
+	// classifiers converted by this version keep learning
+	c = NewClassifierTfIdf(Good, Bad)
+	c.Learn([]string{"tall"}, Good)
+	c.ConvertTermsFreqToTfIdf()
+	buf.Reset()
+	_, err = c.WriteTo(&buf)
+	Assert(t, err == nil, err)
+	d, err = NewClassifierFromReader(&buf)
+	Assert(t, err == nil, err)
+	Assert(t, d.LearnE([]string{"poor"}, Bad) == nil)
+}
+
+// Run with -race: classifying concurrently after learning must
+// refresh the weights once, without racing on the conversion.
+func TestTfIdClassifier_ConcurrentLogScores(t *testing.T) {
+	c := NewClassifierTfIdf(Good, Bad)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "ugly"}, Bad)
 	c.ConvertTermsFreqToTfIdf()
-	Assert(t, false, "Should have panicked:Can only run ConvertTermsFreqToTfIdf() once after a learning cycle.", c)
+	c.Learn([]string{"tall", "blonde"}, Good)
 
+	var wg sync.WaitGroup
+	scores := make([][]float64, 8)
+	for i := range scores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scores[i], _, _ = c.LogScores([]string{"the", "tall", "man"})
+		}()
+	}
+	wg.Wait()
+	for _, s := range scores[1:] {
+		Assert(t, s[0] == scores[0][0] && s[1] == scores[0][1], "scores differ:", s, scores[0])
+	}
+	Assert(t, c.DidConvertTfIdf, "still converted")
 }
 
 func TestTfIdClassifier_LogScore(t *testing.T) {
@@ -357,3 +470,80 @@ func TestTfIdClassifier_LogScore(t *testing.T) {
 	fmt.Printf("%#v", score)
 
 }
+
+func TestExplain(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "ugly"}, Bad)
+
+	doc := []string{"the", "tall", "poor", "poor", "man"}
+	scores, _, _ := c.LogScores(doc)
+	contribs := c.Explain(doc)
+	Assert(t, len(contribs) == 2, "size")
+	Assert(t, contribs[0].Class == Good && contribs[1].Class == Bad, "classes")
+	Assert(t, contribs[0].Score == scores[0], "score")
+	Assert(t, len(contribs[0].Words) == 4, "distinct words")
+
+	// "tall" pulls towards good, "poor" (twice) towards bad
+	top := contribs[0].Top(1)
+	Assert(t, len(top) == 1 && top[0].Word == "tall", "top good:", top)
+	top = contribs[1].Top(1)
+	Assert(t, top[0].Word == "poor" && top[0].Count == 2, "top bad:", top)
+	Assert(t, contribs[0].Words[3].Word == "poor", "last good:", contribs[0].Words)
+	Assert(t, math.Abs(contribs[1].Words[0].Weight+contribs[0].Words[3].Weight) < 1e-9, "symmetric")
+
+	// unseen words are neutral
+	for _, w := range contribs[0].Words {
+		if w.Word == "the" || w.Word == "man" {
+			Assert(t, w.Weight == 0, "neutral:", w)
+		}
+	}
+	Assert(t, len(contribs[0].Top(10)) == 4, "top clamp")
+}
+
+func TestErrors(t *testing.T) {
+	_, err := NewClassifierE(Good)
+	Assert(t, err == ErrTooFewClasses, err)
+	_, err = NewClassifierE(Good, Good, Bad)
+	Assert(t, err == ErrDuplicateClass, err)
+	_, err = NewClassifierTfIdfE()
+	Assert(t, err == ErrTooFewClasses, err)
+	_, err = NewClassifierModelE(Model(42), Good, Bad)
+	Assert(t, err == ErrUnknownModel, err)
+
+	c, err := NewClassifierE(Good, Bad)
+	Assert(t, err == nil, err)
+	err = c.LearnE([]string{"tall"}, "ugly")
+	Assert(t, errors.Is(err, ErrUnknownClass), err)
+	err = c.ObserveE("tall", 1, "ugly")
+	Assert(t, errors.Is(err, ErrUnknownClass), err)
+	Assert(t, c.Learned() == 0 && c.WordCount()[0] == 0, "nothing learned")
+	Assert(t, c.LearnE([]string{"tall"}, Good) == nil)
+
+	d, _ := NewClassifierTfIdfE(Good, Bad)
+	Assert(t, d.LearnE([]string{"tall"}, Good) == nil)
+	_, _, _, err = d.LogScoresE([]string{"tall"})
+	Assert(t, err == ErrNotConverted, err)
+	_, _, _, err = d.ProbScoresE([]string{"tall"})
+	Assert(t, err == ErrNotConverted, err)
+	_, _, _, err = d.SafeProbScores([]string{"tall"})
+	Assert(t, err == ErrNotConverted, err)
+	_, err = d.ClassifyE([]string{"tall"}, ClassifyOptions{})
+	Assert(t, err == ErrNotConverted, err)
+	_, err = d.ExplainE([]string{"tall"})
+	Assert(t, err == ErrNotConverted, err)
+	Assert(t, d.Seen() == 0, "seen")
+
+	d.ConvertTermsFreqToTfIdf()
+	_, _, _, err = d.LogScoresE([]string{"tall"})
+	Assert(t, err == nil, err)
+}
+
+func TestUnknownClassPanics(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		Assert(t, errors.Is(err, ErrUnknownClass), "should have panicked with ErrUnknownClass:", err)
+	}()
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall"}, "ugly")
+}
@@ -0,0 +1,101 @@
+package bayesian
+
+import "sort"
+
+// Unknown is the class of a Classification that was rejected
+// because no class was probable enough. A classifier may have a
+// class named "" too, so use IsUnknown to tell them apart.
+const Unknown Class = ""
+
+// ClassifyOptions configures Classify.
+type ClassifyOptions struct {
+	// TopK is the number of most probable classes returned.
+	// Zero returns all classes.
+	TopK int
+	// MinProb rejects the document when the probability of
+	// the best class is below it.
+	MinProb float64
+	// MinMargin rejects the document when the probability of
+	// the best class exceeds the one of the runner-up by less
+	// than it.
+	MinMargin float64
+}
+
+// Prediction is a class with its normalised probability.
+type Prediction struct {
+	Class Class
+	Prob  float64
+}
+
+// Classification is the result of Classify.
+type Classification struct {
+	// Class is the most probable class, or Unknown if the
+	// document was rejected.
+	Class Class
+	// Prob is the probability of the most probable class.
+	Prob float64
+	// Margin is the difference between the probabilities of
+	// the two most probable classes.
+	Margin float64
+	// Top holds the most probable classes, in descending
+	// order of probability. It is filled in even when the
+	// document was rejected.
+	Top []Prediction
+
+	rejected bool
+}
+
+// IsUnknown reports whether the document was rejected.
+func (r Classification) IsUnknown() bool {
+	return r.rejected
+}
+
+// Classify returns the most probable classes for the document,
+// with probabilities normalised from LogScores using the
+// log-sum-exp trick, so that they do not underflow. The document
+// is rejected as Unknown when the best probability or its margin
+// over the runner-up falls below the thresholds of opts, which
+// lets low-confidence documents be reviewed by hand rather than
+// being mis-filed.
+func (c *Classifier) Classify(document []string, opts ClassifyOptions) Classification {
+	result, err := c.ClassifyE(document, opts)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ClassifyE works the same as Classify, but returns
+// ErrNotConverted instead of panicking.
+func (c *Classifier) ClassifyE(document []string, opts ClassifyOptions) (Classification, error) {
+	scores, _, _, err := c.LogScoresE(document)
+	if err != nil {
+		return Classification{}, err
+	}
+	probs := softmax(scores)
+
+	top := make([]Prediction, len(probs))
+	for index, class := range c.Classes {
+		top[index] = Prediction{Class: class, Prob: probs[index]}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].Prob > top[j].Prob
+	})
+
+	result := Classification{
+		Class: top[0].Class,
+		Prob:  top[0].Prob,
+	}
+	if len(top) > 1 {
+		result.Margin = top[0].Prob - top[1].Prob
+	}
+	if opts.TopK > 0 && opts.TopK < len(top) {
+		top = top[:opts.TopK]
+	}
+	result.Top = top
+	if result.Prob < opts.MinProb || result.Margin < opts.MinMargin {
+		result.Class = Unknown
+		result.rejected = true
+	}
+	return result, nil
+}
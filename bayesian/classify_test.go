@@ -0,0 +1,59 @@
+package bayesian
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	const Neutral Class = "neutral"
+	c := NewClassifier(Good, Bad, Neutral)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "ugly"}, Bad)
+	c.Learn([]string{"average", "man"}, Neutral)
+
+	r := c.Classify([]string{"tall", "rich", "man"}, ClassifyOptions{TopK: 2})
+	Assert(t, r.Class == Good && !r.IsUnknown(), r)
+	Assert(t, len(r.Top) == 2 && r.Top[0].Class == Good, r.Top)
+	Assert(t, r.Top[0].Prob > r.Top[1].Prob, r.Top)
+	Assert(t, r.Prob == r.Top[0].Prob && r.Margin == r.Top[0].Prob-r.Top[1].Prob, r)
+
+	all := c.Classify([]string{"tall", "rich", "man"}, ClassifyOptions{})
+	sum := float64(0)
+	for _, p := range all.Top {
+		sum += p.Prob
+	}
+	Assert(t, len(all.Top) == 3 && math.Abs(sum-1) < 1e-12, all.Top)
+	Assert(t, c.Seen() == 2, "seen")
+
+	// "man" only appears in neutral, but "tall" in good: too close
+	r = c.Classify([]string{"tall", "man"}, ClassifyOptions{MinMargin: 0.5})
+	Assert(t, r.IsUnknown(), r)
+	Assert(t, len(r.Top) == 3, "top is filled in when rejected")
+
+	r = c.Classify([]string{"nothing", "known"}, ClassifyOptions{MinProb: 0.5})
+	Assert(t, r.IsUnknown(), r)
+}
+
+func TestClassifyEmptyClass(t *testing.T) {
+	c := NewClassifier(Good, "")
+	c.Learn([]string{"tall"}, Good)
+	c.Learn([]string{"poor"}, "")
+
+	r := c.Classify([]string{"poor"}, ClassifyOptions{})
+	Assert(t, r.Class == "" && !r.IsUnknown(), "a class named \"\" is not a rejection", r)
+	r = c.Classify([]string{"poor"}, ClassifyOptions{MinProb: 1})
+	Assert(t, r.Class == Unknown && r.IsUnknown(), r)
+}
+
+func TestClassifyNoUnderflow(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"word"}, Good)
+	c.Learn([]string{"other"}, Bad)
+	document := make([]string, 1000)
+	for i := range document {
+		document[i] = "word"
+	}
+	r := c.Classify(document, ClassifyOptions{MinProb: 0.99})
+	Assert(t, r.Class == Good && r.Prob == 1, r)
+}
@@ -0,0 +1,108 @@
+package bayesian
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDecayUnsupported is returned when decaying a classifier that
+// does not use the Multinomial model, or that uses TF-IDF.
+var ErrDecayUnsupported = errors.New("decay needs a multinomial classifier without TF-IDF")
+
+// maxUnit is the weight of a new observation above which every
+// weight is rescaled, before they overflow.
+const maxUnit = 1e64
+
+// SetDecay enables exponential time decay: every call to Learn
+// first multiplies the weight of everything learned so far by
+// factor, so that older documents matter less and less as newer
+// ones arrive. A factor of 0.999 halves the weight of a document
+// after about 700 newer ones. A factor of 0 disables decay.
+//
+// The decay is applied lazily, by giving new documents more
+// weight rather than rewriting every frequency, so Learn stays
+// as cheap as without decay. It is saved along with the
+// classifier. Only the Multinomial model without TF-IDF supports
+// decay, otherwise ErrDecayUnsupported is returned.
+//
+// Decay changes the weights of the words and documents, which
+// probabilities and priors are derived from, but not the counts
+// returned by WordCount, DocumentCount and Learned.
+func (c *Classifier) SetDecay(factor float64) error {
+	if err := c.checkDecay(factor); err != nil {
+		return err
+	}
+	if factor == 1 {
+		factor = 0
+	}
+	c.decay = factor
+	return nil
+}
+
+// DecayFactor returns the factor set with SetDecay, or 0 when
+// decay is disabled.
+func (c *Classifier) DecayFactor() float64 {
+	return c.decay
+}
+
+// Decay multiplies the weight of everything learned so far by
+// factor, for example to fade out seasonal vocabulary at the end
+// of a season. It works whether SetDecay was called or not, and
+// costs the same as a single Learn.
+func (c *Classifier) Decay(factor float64) error {
+	if err := c.checkDecay(factor); err != nil {
+		return err
+	}
+	if factor == 0 {
+		return fmt.Errorf("decay factor %v out of (0, 1]", factor)
+	}
+	c.age(factor)
+	return nil
+}
+
+// checkDecay returns an error if the classifier can't be
+// decayed by factor.
+func (c *Classifier) checkDecay(factor float64) error {
+	if c.model != Multinomial || c.tfIdf {
+		return ErrDecayUnsupported
+	}
+	if factor < 0 || factor > 1 {
+		return fmt.Errorf("decay factor %v out of [0, 1]", factor)
+	}
+	return nil
+}
+
+// unitWeight returns the weight of a new observation. Dividing
+// a stored weight by it gives the current weight.
+func (c *Classifier) unitWeight() float64 {
+	if c.unit == 0 {
+		return 1
+	}
+	return c.unit
+}
+
+// age multiplies the current weight of everything learned by
+// factor.
+func (c *Classifier) age(factor float64) {
+	c.unit = c.unitWeight() / factor
+	if c.unit > maxUnit {
+		for class, data := range c.datas {
+			c.datas[class] = data.scaled(1 / c.unit)
+		}
+		c.unit = 1
+	}
+	c.cache.Store(nil)
+}
+
+// scaled returns a copy of d with its weights multiplied by
+// scale.
+func (d *classData) scaled(scale float64) *classData {
+	s := *d
+	s.Freqs = make(map[string]float64, len(d.Freqs))
+	for word, freq := range d.Freqs {
+		s.Freqs[word] = freq * scale
+	}
+	s.Mass *= scale
+	s.DocMass *= scale
+	return &s
+}
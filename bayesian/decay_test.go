@@ -0,0 +1,102 @@
+package bayesian
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func approxSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecay(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	Assert(t, c.SetDecay(1.5) != nil, "factor above 1")
+	Assert(t, c.SetDecay(0.5) == nil)
+	Assert(t, c.DecayFactor() == 0.5)
+	c.Learn([]string{"tall"}, Good)
+	c.Learn([]string{"poor"}, Bad)
+	Assert(t, approxSlice(c.Priors(), []float64{1.0 / 3, 2.0 / 3}), c.Priors())
+	c.Learn([]string{"rich"}, Good)
+	// tall 0.25, poor 0.5, rich 1
+	Assert(t, approxSlice(c.Priors(), []float64{1.25 / 1.75, 0.5 / 1.75}), c.Priors())
+	Assert(t, math.Abs(c.datas[Good].getWordProb("tall")-0.2) < 1e-12)
+	Assert(t, c.Learned() == 3 && c.WordCount()[0] == 2, "counts do not decay")
+
+	Assert(t, c.Decay(0.5) == nil)
+	Assert(t, c.Decay(0) != nil, "decaying to nothing")
+	c.SetDecay(0)
+	c.Learn([]string{"ugly"}, Bad)
+	// tall 0.125, poor 0.25, rich 0.5, ugly 1
+	Assert(t, approxSlice(c.Priors(), []float64{0.625 / 1.875, 1.25 / 1.875}), c.Priors())
+	top := c.Stats().Classes[1].TopWords
+	Assert(t, top[0].Word == "ugly" && top[0].Count == 1 && top[1].Count == 0.25, top)
+
+	var buf bytes.Buffer
+	_, err := c.WriteTo(&buf)
+	Assert(t, err == nil, err)
+	d, err := NewClassifierFromReader(&buf)
+	Assert(t, err == nil, err)
+	Assert(t, approxSlice(d.Priors(), c.Priors()), d.Priors())
+
+	dir := t.TempDir()
+	Assert(t, c.WriteToDir(dir) == nil)
+	d, err = NewClassifierFromDir(dir)
+	Assert(t, err == nil, err)
+	Assert(t, approxSlice(d.Priors(), c.Priors()), d.Priors())
+	d.Learn([]string{"kind"}, Good)
+	c.Learn([]string{"kind"}, Good)
+	Assert(t, approxSlice(d.Priors(), c.Priors()), d.Priors(), c.Priors())
+
+	e := NewClassifier(Good, Bad)
+	e.Learn([]string{"kind"}, Bad)
+	Assert(t, e.Merge(c) == nil)
+	// c weighs 1.625 for good and 1.25 for bad, e learned 1 bad
+	Assert(t, approxSlice(e.Priors(), []float64{1.625 / 3.875, 2.25 / 3.875}), e.Priors())
+}
+
+func TestDecayRescale(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.SetDecay(0.1)
+	for i := 0; i < 200; i++ {
+		c.Learn([]string{"tall"}, Good)
+	}
+	c.Learn([]string{"poor"}, Bad)
+	Assert(t, c.unit <= maxUnit, c.unit)
+	priors := c.Priors()
+	Assert(t, math.Abs(priors[0]-0.1) < 1e-9, priors)
+	scores, inx, _ := c.LogScores([]string{"poor"})
+	Assert(t, inx == 1 && !math.IsInf(scores[1], 0), scores)
+}
+
+func TestDecayUnsupported(t *testing.T) {
+	Assert(t, NewClassifierModel(Bernoulli, Good, Bad).SetDecay(0.9) == ErrDecayUnsupported)
+	Assert(t, NewClassifierModel(Complement, Good, Bad).Decay(0.9) == ErrDecayUnsupported)
+	err := NewClassifierTfIdf(Good, Bad).SetDecay(0.9)
+	Assert(t, errors.Is(err, ErrDecayUnsupported), err)
+}
+
+func TestDecayPrune(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.SetDecay(0.5)
+	c.Learn([]string{"old"}, Good)
+	for i := 0; i < 10; i++ {
+		c.Learn([]string{"new"}, Good)
+	}
+	Assert(t, c.unitWeight() == 2048, c.unitWeight())
+	// old weighs 0.5^10, new nearly 2
+	Assert(t, c.Prune(1, 0) == 1, "decayed word kept")
+	Assert(t, c.Prune(2, 0) == 1, "recent word dropped")
+	_, ok := c.datas[Good].Freqs["new"]
+	Assert(t, !ok, "new kept")
+}
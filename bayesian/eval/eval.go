@@ -0,0 +1,237 @@
+// Package eval measures the quality of bayesian classifiers
+// with k-fold cross-validation, confusion matrices and per
+// class precision, recall and F1 scores.
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/XiBao/goutil/bayesian"
+)
+
+// DefaultFolds is the number of folds used by CrossValidate
+// when Options.Folds is not set.
+const DefaultFolds = 5
+
+// Document is a labeled, already tokenized document.
+type Document struct {
+	Class bayesian.Class
+	Words []string
+}
+
+// Options configures CrossValidate.
+type Options struct {
+	// Folds is the k in k-fold. Defaults to DefaultFolds.
+	Folds int
+	// Seed seeds the shuffling of documents into folds. The
+	// same seed and documents always give the same report.
+	Seed int64
+	// Classes fixes the order of the classes in the report.
+	// Defaults to the order of first appearance in the
+	// documents.
+	Classes []bayesian.Class
+	// NewClassifier builds the classifier trained on every
+	// fold. Defaults to bayesian.NewClassifierE. TF-IDF
+	// classifiers are converted after training.
+	NewClassifier func(classes ...bayesian.Class) (*bayesian.Classifier, error)
+}
+
+// ClassReport holds the metrics of a single class.
+type ClassReport struct {
+	Class     bayesian.Class
+	Precision float64
+	Recall    float64
+	F1        float64
+	// Support is the number of documents of the class.
+	Support int
+}
+
+// Report holds the result of an evaluation.
+type Report struct {
+	Classes []bayesian.Class
+	// Total is the number of documents evaluated.
+	Total    int
+	Accuracy float64
+	// PerClass is indexed like Classes.
+	PerClass []ClassReport
+	// Confusion[i][j] is the number of documents of class
+	// Classes[i] that were classified as Classes[j].
+	Confusion [][]int
+}
+
+// CrossValidate runs a stratified k-fold cross-validation over
+// the documents: each fold is classified by a classifier trained
+// on all the other folds, and the predictions of all folds are
+// gathered into a single report.
+func CrossValidate(docs []Document, opts Options) (*Report, error) {
+	k := opts.Folds
+	if k == 0 {
+		k = DefaultFolds
+	}
+	if k < 2 {
+		return nil, fmt.Errorf("eval: need at least 2 folds, got %d", k)
+	}
+	if len(docs) < k {
+		return nil, fmt.Errorf("eval: %d documents are not enough for %d folds", len(docs), k)
+	}
+	newClassifier := opts.NewClassifier
+	if newClassifier == nil {
+		newClassifier = bayesian.NewClassifierE
+	}
+	classes := opts.Classes
+	if classes == nil {
+		classes = documentClasses(docs)
+	}
+	if len(classes) < 2 {
+		return nil, fmt.Errorf("eval: need at least 2 classes, got %d", len(classes))
+	}
+	index, err := classIndex(classes, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	// stratify: shuffle the documents of every class and deal
+	// them over the folds, so that each fold has about the same
+	// class balance as the whole set.
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	byClass := make([][]int, len(classes))
+	for i, doc := range docs {
+		j := index[doc.Class]
+		byClass[j] = append(byClass[j], i)
+	}
+	fold := make([]int, len(docs))
+	next := 0
+	for _, members := range byClass {
+		rnd.Shuffle(len(members), func(i, j int) {
+			members[i], members[j] = members[j], members[i]
+		})
+		for _, i := range members {
+			fold[i] = next % k
+			next++
+		}
+	}
+
+	report := newReport(classes)
+	for f := 0; f < k; f++ {
+		c, err := newClassifier(classes...)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range docs {
+			if fold[i] != f {
+				c.Learn(doc.Words, doc.Class)
+			}
+		}
+		if c.IsTfIdf() {
+			c.ConvertTermsFreqToTfIdf()
+		}
+		for i, doc := range docs {
+			if fold[i] == f {
+				_, inx, _, err := c.LogScoresE(doc.Words)
+				if err != nil {
+					return nil, err
+				}
+				report.Confusion[index[doc.Class]][inx]++
+			}
+		}
+	}
+	report.compute()
+	return report, nil
+}
+
+// Evaluate classifies the documents with an already trained
+// classifier and reports how well it did, for example on a
+// held-out test set. A TF-IDF classifier must have been
+// converted, or bayesian.ErrNotConverted is returned.
+func Evaluate(c *bayesian.Classifier, docs []Document) (*Report, error) {
+	index, err := classIndex(c.Classes, docs)
+	if err != nil {
+		return nil, err
+	}
+	report := newReport(c.Classes)
+	for _, doc := range docs {
+		_, inx, _, err := c.LogScoresE(doc.Words)
+		if err != nil {
+			return nil, err
+		}
+		report.Confusion[index[doc.Class]][inx]++
+	}
+	report.compute()
+	return report, nil
+}
+
+func newReport(classes []bayesian.Class) *Report {
+	n := len(classes)
+	r := &Report{
+		Classes:   classes,
+		PerClass:  make([]ClassReport, n),
+		Confusion: make([][]int, n),
+	}
+	for i := range r.Confusion {
+		r.Confusion[i] = make([]int, n)
+	}
+	return r
+}
+
+// compute fills in the metrics from the confusion matrix.
+func (r *Report) compute() {
+	n := len(r.Classes)
+	correct := 0
+	for i := 0; i < n; i++ {
+		var support, predicted int
+		for j := 0; j < n; j++ {
+			support += r.Confusion[i][j]
+			predicted += r.Confusion[j][i]
+		}
+		tp := r.Confusion[i][i]
+		correct += tp
+		r.Total += support
+
+		cr := ClassReport{
+			Class:   r.Classes[i],
+			Support: support,
+		}
+		if predicted > 0 {
+			cr.Precision = float64(tp) / float64(predicted)
+		}
+		if support > 0 {
+			cr.Recall = float64(tp) / float64(support)
+		}
+		if cr.Precision+cr.Recall > 0 {
+			cr.F1 = 2 * cr.Precision * cr.Recall / (cr.Precision + cr.Recall)
+		}
+		r.PerClass[i] = cr
+	}
+	if r.Total > 0 {
+		r.Accuracy = float64(correct) / float64(r.Total)
+	}
+}
+
+// documentClasses returns the classes of the documents in
+// order of first appearance.
+func documentClasses(docs []Document) (classes []bayesian.Class) {
+	seen := make(map[bayesian.Class]bool)
+	for _, doc := range docs {
+		if !seen[doc.Class] {
+			seen[doc.Class] = true
+			classes = append(classes, doc.Class)
+		}
+	}
+	return
+}
+
+// classIndex maps every class to its index and checks that
+// the documents only use known classes.
+func classIndex(classes []bayesian.Class, docs []Document) (map[bayesian.Class]int, error) {
+	index := make(map[bayesian.Class]int, len(classes))
+	for i, class := range classes {
+		index[class] = i
+	}
+	for i, doc := range docs {
+		if _, ok := index[doc.Class]; !ok {
+			return nil, fmt.Errorf("eval: document %d has unknown class %q", i, doc.Class)
+		}
+	}
+	return index, nil
+}
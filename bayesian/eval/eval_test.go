@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/XiBao/goutil/bayesian"
+)
+
+const (
+	Phone bayesian.Class = "phone"
+	Dress bayesian.Class = "dress"
+)
+
+func corpus() []Document {
+	var docs []Document
+	for i := 0; i < 10; i++ {
+		docs = append(docs,
+			Document{Phone, []string{"phone", "5g", "screen"}},
+			Document{Dress, []string{"dress", "silk", "summer"}},
+		)
+	}
+	return docs
+}
+
+func TestCrossValidate(t *testing.T) {
+	report, err := CrossValidate(corpus(), Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 20 || report.Accuracy != 1 {
+		t.Fatalf("total %d accuracy %v", report.Total, report.Accuracy)
+	}
+	if !reflect.DeepEqual(report.Classes, []bayesian.Class{Phone, Dress}) {
+		t.Fatalf("classes %v", report.Classes)
+	}
+	if !reflect.DeepEqual(report.Confusion, [][]int{{10, 0}, {0, 10}}) {
+		t.Fatalf("confusion %v", report.Confusion)
+	}
+	for _, cr := range report.PerClass {
+		if cr.Precision != 1 || cr.Recall != 1 || cr.F1 != 1 || cr.Support != 10 {
+			t.Fatalf("class report %+v", cr)
+		}
+	}
+}
+
+func TestCrossValidateDeterministic(t *testing.T) {
+	docs := corpus()
+	// ambiguous documents make the result depend on the folds
+	for i := 0; i < 6; i++ {
+		docs = append(docs, Document{Phone, []string{"silk", "phone"}}, Document{Dress, []string{"screen", "dress"}})
+	}
+	a, err := CrossValidate(docs, Options{Folds: 4, Seed: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := CrossValidate(docs, Options{Folds: 4, Seed: 42})
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("not deterministic: %+v != %+v", a, b)
+	}
+}
+
+func TestCrossValidateErrors(t *testing.T) {
+	if _, err := CrossValidate(corpus(), Options{Folds: 1}); err == nil {
+		t.Fatal("expected error for 1 fold")
+	}
+	if _, err := CrossValidate(corpus()[:3], Options{Folds: 4}); err == nil {
+		t.Fatal("expected error for too few documents")
+	}
+	if _, err := CrossValidate(corpus(), Options{Classes: []bayesian.Class{Phone, "other"}}); err == nil {
+		t.Fatal("expected error for unknown class")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := bayesian.NewClassifier(Phone, Dress)
+	c.Learn([]string{"phone"}, Phone)
+	c.Learn([]string{"dress"}, Dress)
+
+	report, err := Evaluate(c, []Document{
+		{Phone, []string{"phone"}},
+		{Phone, []string{"phone"}},
+		{Phone, []string{"dress"}},
+		{Dress, []string{"dress"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Confusion, [][]int{{2, 1}, {0, 1}}) {
+		t.Fatalf("confusion %v", report.Confusion)
+	}
+	if report.Accuracy != 0.75 {
+		t.Fatalf("accuracy %v", report.Accuracy)
+	}
+	phone, dress := report.PerClass[0], report.PerClass[1]
+	if phone.Precision != 1 || math.Abs(phone.Recall-2.0/3) > 1e-12 || math.Abs(phone.F1-0.8) > 1e-12 {
+		t.Fatalf("phone %+v", phone)
+	}
+	if dress.Precision != 0.5 || dress.Recall != 1 || math.Abs(dress.F1-2.0/3) > 1e-12 {
+		t.Fatalf("dress %+v", dress)
+	}
+}
+
+func TestTfIdf(t *testing.T) {
+	report, err := CrossValidate(corpus(), Options{Seed: 1, NewClassifier: bayesian.NewClassifierTfIdfE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Accuracy != 1 {
+		t.Fatalf("accuracy %v", report.Accuracy)
+	}
+
+	c := bayesian.NewClassifierTfIdf(Phone, Dress)
+	c.Learn([]string{"phone"}, Phone)
+	c.Learn([]string{"dress"}, Dress)
+	if _, err := Evaluate(c, corpus()); !errors.Is(err, bayesian.ErrNotConverted) {
+		t.Fatalf("unconverted classifier: %v", err)
+	}
+	c.ConvertTermsFreqToTfIdf()
+	if _, err := Evaluate(c, corpus()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDuplicateClasses(t *testing.T) {
+	_, err := CrossValidate(corpus(), Options{Classes: []bayesian.Class{Phone, Dress, Phone}})
+	if !errors.Is(err, bayesian.ErrDuplicateClass) {
+		t.Fatalf("duplicate classes: %v", err)
+	}
+}
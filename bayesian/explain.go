@@ -0,0 +1,124 @@
+package bayesian
+
+import "sort"
+
+// WordContribution is the weight a single word added to the
+// log score of a class: log P(W|C_j) minus the average of
+// log P(W|C) over all classes, multiplied by the number of
+// times the word appears in the document. Positive weights
+// pull the document towards the class, negative weights
+// push it away.
+//
+// For the Bernoulli and Complement models the log probability
+// is replaced by the weight the model gives to the word, and
+// words outside the vocabulary have no weight.
+type WordContribution struct {
+	Word   string
+	Count  int
+	Weight float64
+}
+
+// Contribution explains the log score of a single class.
+type Contribution struct {
+	Class Class
+	// Score is the log score of the class, as returned
+	// by LogScores.
+	Score float64
+	// Words holds every distinct word of the document,
+	// sorted by descending weight.
+	Words []WordContribution
+}
+
+// Top returns at most n words that contributed the most
+// to the score of the class.
+func (c Contribution) Top(n int) []WordContribution {
+	if n < 0 || n > len(c.Words) {
+		n = len(c.Words)
+	}
+	return c.Words[:n]
+}
+
+// Explain returns, for each class, the words of the document
+// that contributed the most to its log score. It is meant to
+// help debugging misclassifications: the contributions of the
+// winning class usually show which words pulled the document
+// into it.
+//
+// The index j of the contribution corresponds to the class
+// given by c.Classes[j].
+func (c *Classifier) Explain(document []string) []Contribution {
+	result, err := c.ExplainE(document)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ExplainE works the same as Explain, but returns
+// ErrNotConverted instead of panicking.
+func (c *Classifier) ExplainE(document []string) ([]Contribution, error) {
+	scores, _, _, err := c.LogScoresE(document)
+	if err != nil {
+		return nil, err
+	}
+
+	// count distinct words, keeping the order of
+	// appearance so that ties are stable
+	counts := make(map[string]int, len(document))
+	words := make([]string, 0, len(document))
+	for _, word := range document {
+		if counts[word] == 0 {
+			words = append(words, word)
+		}
+		counts[word]++
+	}
+
+	n := len(c.Classes)
+	logProbs := make([][]float64, n)
+	for index := range logProbs {
+		logProbs[index] = make([]float64, len(words))
+	}
+	var m *modelCache
+	if c.model != Multinomial {
+		m = c.stats()
+	}
+	weights := make([]float64, n)
+	for j, word := range words {
+		if !c.wordWeights(m, c.feature(word), weights) {
+			continue
+		}
+		for index := range logProbs {
+			logProbs[index][j] = weights[index]
+		}
+		if c.model == Bernoulli {
+			// only presence counts
+			counts[word] = 1
+		}
+	}
+
+	result := make([]Contribution, n)
+	for index, class := range c.Classes {
+		contribs := make([]WordContribution, len(words))
+		for j, word := range words {
+			avg := float64(0)
+			for i := 0; i < n; i++ {
+				avg += logProbs[i][j]
+			}
+			avg /= float64(n)
+			contribs[j] = WordContribution{
+				Word:   word,
+				Count:  counts[word],
+				Weight: float64(counts[word]) * (logProbs[index][j] - avg),
+			}
+		}
+		sort.SliceStable(contribs, func(i, j int) bool {
+			return contribs[i].Weight > contribs[j].Weight
+		})
+		result[index] = Contribution{
+			Class: class,
+			Score: scores[index],
+			Words: contribs,
+		}
+	}
+	return result, nil
+}
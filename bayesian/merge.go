@@ -0,0 +1,85 @@
+package bayesian
+
+import "errors"
+
+// ErrIncompatible is returned when merging classifiers that
+// use different models or feature hashing settings, or that do
+// not all use TF-IDF, or a nil classifier.
+var ErrIncompatible = errors.New("classifiers are not compatible")
+
+// Merge adds everything the other classifiers learned to c, as
+// if c had learned their documents itself: the word frequencies,
+// word totals, document counts and TF samples of matching
+// classes are summed. Classes that c does not know yet are
+// appended to c.Classes. The number of documents seen is not
+// merged.
+//
+// This makes map-reduce style training possible: train one
+// classifier per shard of the data, then merge them. All the
+// classifiers must use the same model and feature hashing, and
+// either all or none of them must use TF-IDF, otherwise
+// ErrIncompatible is returned and c is left untouched. The TF-IDF
+// weights of a converted classifier are recomputed on its next
+// classification. With decay, the documents of the others keep
+// the weight they had and do not age any further while merged.
+// With PriorWeights, the classes appended take the prior weight
+// the other classifier gave them, or 1. TF-IDF classifiers
+// converted by an older version can't be merged, ErrLegacyTfIdf
+// is returned.
+func (c *Classifier) Merge(others ...*Classifier) error {
+	for _, o := range others {
+		if o == nil {
+			return ErrIncompatible
+		}
+		if c.legacyTfIdf || o.legacyTfIdf {
+			return ErrLegacyTfIdf
+		}
+		if o.model != c.model || o.tfIdf != c.tfIdf || o.buckets != c.buckets {
+			return ErrIncompatible
+		}
+	}
+	for _, o := range others {
+		scale := c.unitWeight() / o.unitWeight()
+		for _, class := range o.Classes {
+			data, ok := c.datas[class]
+			if !ok {
+				data = newClassData()
+				c.datas[class] = data
+				// never append to a slice the caller may own
+				c.Classes = append(c.Classes[:len(c.Classes):len(c.Classes)], class)
+				if c.weights != nil {
+					weight, ok := o.weights[class]
+					if !ok {
+						weight = 1
+					}
+					c.weights[class] = weight
+				}
+			}
+			data.merge(o.datas[class], scale)
+		}
+		c.learned += o.learned
+	}
+	if c.tfIdf && c.DidConvertTfIdf {
+		c.tfIdfStale.Store(true)
+	}
+	c.cache.Store(nil)
+	return nil
+}
+
+// merge adds the raw statistics of o to d, multiplying the
+// word frequencies of o by scale.
+func (d *classData) merge(o *classData, scale float64) {
+	for word, freq := range o.Freqs {
+		d.Freqs[word] += freq * scale
+	}
+	for word, tfs := range o.FreqTfs {
+		d.FreqTfs[word] = append(d.FreqTfs[word], tfs...)
+	}
+	for word, df := range o.DocFreqs {
+		d.DocFreqs[word] += df
+	}
+	d.Total += o.Total
+	d.Docs += o.Docs
+	d.Mass += o.Mass * scale
+	d.DocMass += o.DocMass * scale
+}
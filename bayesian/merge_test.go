@@ -0,0 +1,99 @@
+package bayesian
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	docs := []struct {
+		words []string
+		class Class
+	}{
+		{[]string{"tall", "handsome", "rich"}, Good},
+		{[]string{"bald", "poor", "ugly"}, Bad},
+		{[]string{"tall", "kind"}, Good},
+		{[]string{"poor", "rude", "rude"}, Bad},
+	}
+	doc := []string{"tall", "rude", "man"}
+
+	for _, model := range []Model{Multinomial, Bernoulli, Complement} {
+		whole := NewClassifierModel(model, Good, Bad)
+		a := NewClassifierModel(model, Good, Bad)
+		b := NewClassifierModel(model, Good, Bad)
+		for i, d := range docs {
+			whole.Learn(d.words, d.class)
+			if i%2 == 0 {
+				a.Learn(d.words, d.class)
+			} else {
+				b.Learn(d.words, d.class)
+			}
+		}
+		Assert(t, a.Merge(b) == nil)
+		Assert(t, a.Learned() == whole.Learned(), model, "learned")
+		Assert(t, reflect.DeepEqual(a.WordCount(), whole.WordCount()), model, "word count")
+		want, _, _ := whole.LogScores(doc)
+		got, _, _ := a.LogScores(doc)
+		for i := range want {
+			Assert(t, math.Abs(want[i]-got[i]) < 1e-9, model, got, want)
+		}
+	}
+}
+
+func TestMergeDisjointClasses(t *testing.T) {
+	const Neutral Class = "neutral"
+	classes := make([]Class, 2, 10)
+	classes[0], classes[1] = Good, Bad
+	a := NewClassifier(classes...)
+	a.Learn([]string{"tall"}, Good)
+	b := NewClassifier(Bad, Neutral)
+	b.Learn([]string{"average"}, Neutral)
+	b.Learn([]string{"poor"}, Bad)
+
+	Assert(t, a.Merge(b) == nil)
+	Assert(t, reflect.DeepEqual(a.Classes, []Class{Good, Bad, Neutral}), a.Classes)
+	Assert(t, classes[:3][2] == "", "caller's slice was modified")
+	Assert(t, a.Learned() == 3)
+	Assert(t, reflect.DeepEqual(a.WordCount(), []int{1, 1, 1}), a.WordCount())
+	_, inx, _ := a.LogScores([]string{"average"})
+	Assert(t, inx == 2, "neutral")
+}
+
+func TestMergePriorWeights(t *testing.T) {
+	const Neutral, Other Class = "neutral", "other"
+	a := NewClassifier(Good, Bad)
+	a.Learn([]string{"tall"}, Good)
+	Assert(t, a.SetPriorWeights(map[Class]float64{Good: 1, Bad: 1}) == nil)
+	b := NewClassifier(Bad, Neutral)
+	b.Learn([]string{"average"}, Neutral)
+	Assert(t, b.SetPriorWeights(map[Class]float64{Bad: 1, Neutral: 2}) == nil)
+	c := NewClassifier(Bad, Other)
+	c.Learn([]string{"strange"}, Other)
+
+	Assert(t, a.Merge(b, c) == nil)
+	Assert(t, reflect.DeepEqual(a.Priors(), []float64{0.2, 0.2, 0.4, 0.2}), a.Priors())
+	_, inx, _ := a.LogScores([]string{"average"})
+	Assert(t, inx == 2, "neutral")
+}
+
+func TestMergeTfIdf(t *testing.T) {
+	a := NewClassifierTfIdf(Good, Bad)
+	a.Learn([]string{"tall", "handsome", "rich"}, Good)
+	a.Learn([]string{"fat"}, Bad)
+	a.ConvertTermsFreqToTfIdf()
+	b := NewClassifierTfIdf(Good, Bad)
+	b.Learn([]string{"tall", "blonde"}, Good)
+	b.Learn([]string{"tall"}, Good)
+	b.Learn([]string{"short", "poor"}, Bad)
+
+	Assert(t, a.Merge(b) == nil)
+	score, _, _ := a.LogScores([]string{"the", "tall", "man"})
+	// same documents as TestTfIdClassifier_LogScore
+	Assert(t, math.Abs(score[0]-(-53.028113582945196)) < 1e-9, score)
+
+	Assert(t, a.Merge(NewClassifier(Good, Bad)) == ErrIncompatible)
+	Assert(t, a.Merge(NewClassifierModel(Bernoulli, Good, Bad)) == ErrIncompatible)
+	Assert(t, a.Merge(NewClassifierTfIdf(Good, Bad), nil) == ErrIncompatible)
+	Assert(t, a.Learned() == 5, "left untouched")
+}
@@ -0,0 +1,207 @@
+package bayesian
+
+import "math"
+
+// Model selects the Naive Bayes event model a classifier uses
+// to score documents.
+type Model int
+
+const (
+	// Multinomial scores documents by how often every word
+	// appears in a class. It is the default model.
+	Multinomial Model = iota
+	// Bernoulli only looks at the presence or absence of every
+	// vocabulary word in a document, which works better for
+	// short texts such as product titles. Priors are derived
+	// from document counts.
+	Bernoulli
+	// Complement estimates the word probabilities of a class
+	// from all the other classes, which copes better with
+	// imbalanced training sets. Priors are only used when a
+	// prior source is chosen with SetPriorSource.
+	Complement
+)
+
+// alpha is the Laplace smoothing used by the Bernoulli
+// and Complement models.
+const alpha = 1
+
+// String returns the name of the model.
+func (m Model) String() string {
+	switch m {
+	case Multinomial:
+		return "multinomial"
+	case Bernoulli:
+		return "bernoulli"
+	case Complement:
+		return "complement"
+	}
+	return "unknown"
+}
+
+// modelCache holds statistics derived from all the classes
+// that would be too costly to compute for every document.
+// It is rebuilt lazily after the classifier changes.
+type modelCache struct {
+	vocabulary int       // |V|, the number of distinct words
+	total      float64   // word count over all classes
+	absent     []float64 // Bernoulli: sum of log(1-P(W|C_j)) over the vocabulary
+}
+
+// stats returns the up to date model statistics.
+func (c *Classifier) stats() *modelCache {
+	if m := c.cache.Load(); m != nil {
+		return m
+	}
+	vocab := make(map[string]struct{})
+	m := &modelCache{}
+	for _, class := range c.Classes {
+		data := c.datas[class]
+		for word := range data.Freqs {
+			vocab[word] = struct{}{}
+		}
+		m.total += float64(data.Total)
+	}
+	m.vocabulary = len(vocab)
+
+	if c.model == Bernoulli {
+		m.absent = make([]float64, len(c.Classes))
+		for index, class := range c.Classes {
+			data := c.datas[class]
+			denom := float64(data.Docs + 2*alpha)
+			sum := float64(0)
+			for _, df := range data.DocFreqs {
+				sum += math.Log1p(-(df + alpha) / denom)
+			}
+			// words of the vocabulary never seen in the class
+			unseen := m.vocabulary - len(data.DocFreqs)
+			sum += float64(unseen) * math.Log1p(-alpha/denom)
+			m.absent[index] = sum
+		}
+	}
+	c.cache.Store(m)
+	return m
+}
+
+// inVocabulary reports whether any class has seen the word.
+func (c *Classifier) inVocabulary(word string) bool {
+	for _, data := range c.datas {
+		if _, ok := data.Freqs[word]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// wordWeights sets weights[j] to the amount a single occurrence
+// of the word adds to the log score of class c.Classes[j]. It
+// returns false when the model ignores the word.
+func (c *Classifier) wordWeights(m *modelCache, word string, weights []float64) bool {
+	switch c.model {
+	case Bernoulli:
+		if !c.inVocabulary(word) {
+			return false
+		}
+		for index, class := range c.Classes {
+			data := c.datas[class]
+			p := (data.DocFreqs[word] + alpha) / float64(data.Docs+2*alpha)
+			// swap the absent term for the present one
+			weights[index] = math.Log(p) - math.Log1p(-p)
+		}
+	case Complement:
+		if !c.inVocabulary(word) {
+			return false
+		}
+		count := float64(0)
+		for _, data := range c.datas {
+			count += data.Freqs[word]
+		}
+		for index, class := range c.Classes {
+			data := c.datas[class]
+			theta := (count - data.Freqs[word] + alpha) /
+				(m.total - float64(data.Total) + alpha*float64(m.vocabulary))
+			weights[index] = -math.Log(theta)
+		}
+	default:
+		for index, class := range c.Classes {
+			weights[index] = math.Log(c.datas[class].getWordProb(word))
+		}
+	}
+	return true
+}
+
+// bernoulliLogScores implements logScores for the
+// Bernoulli model.
+func (c *Classifier) bernoulliLogScores(document []string) []float64 {
+	m := c.stats()
+	n := len(c.Classes)
+	scores := make([]float64, n)
+	priors := c.getPriors()
+	for index := range scores {
+		scores[index] = math.Log(priors[index]) + m.absent[index]
+	}
+	weights := make([]float64, n)
+	present := make(map[string]struct{}, len(document))
+	for _, word := range document {
+		if _, ok := present[word]; ok {
+			continue
+		}
+		present[word] = struct{}{}
+		if c.wordWeights(m, word, weights) {
+			for index := range scores {
+				scores[index] += weights[index]
+			}
+		}
+	}
+	return scores
+}
+
+// complementLogScores implements logScores for the
+// Complement model.
+func (c *Classifier) complementLogScores(document []string) []float64 {
+	m := c.stats()
+	n := len(c.Classes)
+	scores := make([]float64, n)
+	if c.priors != PriorModel {
+		for index, prior := range c.getPriors() {
+			scores[index] = math.Log(prior)
+		}
+	}
+	weights := make([]float64, n)
+	for _, word := range document {
+		if c.wordWeights(m, word, weights) {
+			for index := range scores {
+				scores[index] += weights[index]
+			}
+		}
+	}
+	return scores
+}
+
+// softmax turns log scores into probabilities summing to 1
+// without underflowing.
+func softmax(scores []float64) []float64 {
+	probs := make([]float64, len(scores))
+	max := math.Inf(-1)
+	for _, score := range scores {
+		if score > max {
+			max = score
+		}
+	}
+	if math.IsInf(max, -1) {
+		// nothing is known, every class is as likely
+		for i := range probs {
+			probs[i] = 1 / float64(len(probs))
+		}
+		return probs
+	}
+	sum := float64(0)
+	for i, score := range scores {
+		probs[i] = math.Exp(score - max)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
@@ -0,0 +1,82 @@
+package bayesian
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+const (
+	China Class = "china"
+	Japan Class = "japan"
+)
+
+// newChinaJapan trains the classic example 13.1 of "Introduction
+// to Information Retrieval" (Manning, Raghavan, Schütze).
+func newChinaJapan(model Model) *Classifier {
+	c := NewClassifierModel(model, China, Japan)
+	c.Learn([]string{"Chinese", "Beijing", "Chinese"}, China)
+	c.Learn([]string{"Chinese", "Chinese", "Shanghai"}, China)
+	c.Learn([]string{"Chinese", "Macao"}, China)
+	c.Learn([]string{"Tokyo", "Japan", "Chinese"}, Japan)
+	return c
+}
+
+var chinaJapanDoc = []string{"Chinese", "Chinese", "Chinese", "Tokyo", "Japan"}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestBernoulli(t *testing.T) {
+	c := newChinaJapan(Bernoulli)
+	Assert(t, c.Model() == Bernoulli)
+
+	// the book gives P(c|d5) ∝ 0.005 and P(c̄|d5) ∝ 0.022
+	scores, inx, strict := c.LogScores(chinaJapanDoc)
+	Assert(t, approx(scores[0], -5.262178319932163), scores)
+	Assert(t, approx(scores[1], -3.819085009768877), scores)
+	Assert(t, inx == 1 && strict, "should be japan")
+
+	probs, inx, _ := c.ProbScores(chinaJapanDoc)
+	Assert(t, approx(probs[0], 0.19106678876165278), probs)
+	Assert(t, approx(probs[0]+probs[1], 1), probs)
+	Assert(t, inx == 1)
+
+	safe, _, _, err := c.SafeProbScores(chinaJapanDoc)
+	Assert(t, err == nil, err)
+	Assert(t, approx(safe[0], probs[0]), safe)
+
+	// unknown words are ignored by the Bernoulli model
+	other, _, _ := c.LogScores(append(chinaJapanDoc, "Kyoto"))
+	Assert(t, approx(other[0], scores[0]) && approx(other[1], scores[1]), other)
+}
+
+func TestComplement(t *testing.T) {
+	c := newChinaJapan(Complement)
+
+	scores, inx, strict := c.LogScores(chinaJapanDoc)
+	Assert(t, approx(scores[0], 7.520386983881371), scores)
+	Assert(t, approx(scores[1], 7.820008240392128), scores)
+	Assert(t, inx == 1 && strict, "should be japan")
+
+	probs, _, _ := c.ProbScores(chinaJapanDoc)
+	Assert(t, approx(probs[0], 0.42565007279170697), probs)
+}
+
+func TestModelGobs(t *testing.T) {
+	c := newChinaJapan(Bernoulli)
+	err := c.WriteToFile("model.ser")
+	Assert(t, err == nil, err)
+	defer os.Remove("model.ser")
+	d, err := NewClassifierFromFile("model.ser")
+	Assert(t, err == nil, err)
+	Assert(t, d.Model() == Bernoulli)
+	scores, _, _ := d.LogScores(chinaJapanDoc)
+	Assert(t, approx(scores[0], -5.262178319932163), scores)
+
+	// learning invalidates the cached statistics
+	d.Learn([]string{"Tokyo", "Osaka"}, Japan)
+	after, _, _ := d.LogScores(chinaJapanDoc)
+	Assert(t, !approx(after[1], scores[1]), after)
+}
@@ -0,0 +1,109 @@
+package bayesian
+
+import "errors"
+
+var (
+	// ErrInvalidPriors is returned when prior weights are negative
+	// or all zero.
+	ErrInvalidPriors = errors.New("invalid prior weights")
+
+	// ErrNoDocumentCounts is returned when PriorDocuments is
+	// chosen for a classifier saved before the number of
+	// documents per class was kept.
+	ErrNoDocumentCounts = errors.New("classifier has no document counts")
+)
+
+// PriorSource selects how the prior probabilities P(C_j) of
+// the classes are derived.
+type PriorSource int
+
+const (
+	// PriorModel uses the default of the model: word counts for
+	// Multinomial, document counts for Bernoulli, and no priors
+	// for Complement.
+	PriorModel PriorSource = iota
+	// PriorWords derives the priors from the number of words
+	// learned per class. It favours classes with long documents.
+	PriorWords
+	// PriorDocuments derives the priors from the number of
+	// documents learned per class. Classifiers saved before
+	// the documents were counted fall back to PriorModel.
+	PriorDocuments
+	// PriorUniform gives every class the same prior.
+	PriorUniform
+	// PriorWeights uses the weights given to SetPriorWeights.
+	PriorWeights
+)
+
+// SetPriorSource chooses how the priors are derived. It can be
+// changed at any time, for example to correct a biased training
+// set without duplicating documents. Use SetPriorWeights for
+// PriorWeights.
+func (c *Classifier) SetPriorSource(source PriorSource) error {
+	switch source {
+	case PriorModel, PriorWords, PriorUniform:
+	case PriorDocuments:
+		if !c.hasDocumentCounts() {
+			return ErrNoDocumentCounts
+		}
+	case PriorWeights:
+		if c.weights == nil {
+			return ErrInvalidPriors
+		}
+	default:
+		return errors.New("unknown prior source")
+	}
+	c.priors = source
+	return nil
+}
+
+// SetPriorWeights sets user supplied priors: the prior of every
+// class is proportional to its weight, and classes missing from
+// weights get 0. The weights must not be negative, nor all zero.
+func (c *Classifier) SetPriorWeights(weights map[Class]float64) error {
+	sum := float64(0)
+	for class, weight := range weights {
+		if _, ok := c.datas[class]; !ok {
+			return unknownClass(class)
+		}
+		if weight < 0 {
+			return ErrInvalidPriors
+		}
+		sum += weight
+	}
+	if sum == 0 {
+		return ErrInvalidPriors
+	}
+	c.weights = make(map[Class]float64, len(weights))
+	for class, weight := range weights {
+		c.weights[class] = weight
+	}
+	c.priors = PriorWeights
+	return nil
+}
+
+// hasDocumentCounts reports whether the documents learned were
+// counted per class, which classifiers saved before they were
+// did not. A classifier that learned nothing yet counts them.
+func (c *Classifier) hasDocumentCounts() bool {
+	learned := false
+	for _, data := range c.datas {
+		if data.DocMass > 0 {
+			return true
+		}
+		learned = learned || data.Mass > 0
+	}
+	return !learned
+}
+
+// PriorSource returns how the priors are derived.
+func (c *Classifier) PriorSource() PriorSource {
+	return c.priors
+}
+
+// Priors returns the prior probabilities of the classes. The
+// index j of the prior corresponds to the class given by
+// c.Classes[j].
+func (c *Classifier) Priors() []float64 {
+	return c.getPriors()
+}
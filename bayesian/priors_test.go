@@ -0,0 +1,85 @@
+package bayesian
+
+import (
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPriorSource(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall", "handsome", "rich", "kind", "smart", "funny"}, Good)
+	c.Learn([]string{"poor"}, Bad)
+	c.Learn([]string{"ugly"}, Bad)
+
+	Assert(t, c.PriorSource() == PriorModel)
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{0.75, 0.25}), c.Priors())
+	Assert(t, c.SetPriorSource(PriorWords) == nil)
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{0.75, 0.25}), c.Priors())
+	Assert(t, c.SetPriorSource(PriorDocuments) == nil)
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{1.0 / 3, 2.0 / 3}), c.Priors())
+	Assert(t, c.SetPriorSource(PriorUniform) == nil)
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{0.5, 0.5}), c.Priors())
+
+	// an unknown word is classified by its prior alone
+	_, inx, strict := c.LogScores([]string{"what"})
+	Assert(t, !strict, "uniform priors")
+	Assert(t, c.SetPriorSource(PriorDocuments) == nil)
+	_, inx, _ = c.LogScores([]string{"what"})
+	Assert(t, inx == 1, "more bad documents")
+
+	Assert(t, c.SetPriorSource(PriorWeights) == ErrInvalidPriors)
+	Assert(t, c.SetPriorSource(PriorSource(42)) != nil)
+}
+
+func TestPriorDocumentsWithoutCounts(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	Assert(t, c.SetPriorSource(PriorDocuments) == nil, "empty classifier")
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"poor"}, Bad)
+	// as saved before the documents were counted
+	for _, data := range c.datas {
+		data.Docs, data.DocMass = 0, 0
+	}
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{0.75, 0.25}), "fallback to the model:", c.Priors())
+	scores, _, _ := c.LogScores([]string{"tall"})
+	Assert(t, !math.IsInf(scores[0], -1) && !math.IsInf(scores[1], -1), scores)
+
+	c.priors = PriorModel
+	Assert(t, errors.Is(c.SetPriorSource(PriorDocuments), ErrNoDocumentCounts))
+	Assert(t, c.PriorSource() == PriorModel)
+}
+
+func TestPriorWeights(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall"}, Good)
+	c.Learn([]string{"poor"}, Bad)
+
+	Assert(t, errors.Is(c.SetPriorWeights(map[Class]float64{"ugly": 1}), ErrUnknownClass))
+	Assert(t, c.SetPriorWeights(map[Class]float64{Good: -1, Bad: 2}) == ErrInvalidPriors)
+	Assert(t, c.SetPriorWeights(map[Class]float64{Good: 0}) == ErrInvalidPriors)
+	Assert(t, c.PriorSource() == PriorModel, "unchanged on error")
+
+	Assert(t, c.SetPriorWeights(map[Class]float64{Good: 1, Bad: 3}) == nil)
+	Assert(t, c.PriorSource() == PriorWeights)
+	Assert(t, reflect.DeepEqual(c.Priors(), []float64{0.25, 0.75}), c.Priors())
+
+	err := c.WriteToFile("priors.ser")
+	Assert(t, err == nil, err)
+	defer os.Remove("priors.ser")
+	d, err := NewClassifierFromFile("priors.ser")
+	Assert(t, err == nil, err)
+	Assert(t, d.PriorSource() == PriorWeights)
+	Assert(t, reflect.DeepEqual(d.Priors(), []float64{0.25, 0.75}), d.Priors())
+}
+
+func TestComplementPriors(t *testing.T) {
+	c := newChinaJapan(Complement)
+	before, _, _ := c.LogScores(chinaJapanDoc)
+	Assert(t, c.SetPriorSource(PriorDocuments) == nil)
+	after, _, _ := c.LogScores(chinaJapanDoc)
+	Assert(t, approx(after[0], before[0]+math.Log(0.75)), after)
+	Assert(t, approx(after[1], before[1]+math.Log(0.25)), after)
+}
@@ -0,0 +1,123 @@
+package bayesian
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/XiBao/goutil"
+)
+
+// ErrNotEmpty is returned when a setting that must be chosen
+// before training is changed on a classifier that has already
+// learned something.
+var ErrNotEmpty = errors.New("classifier has already learned")
+
+// Prune bounds the memory used by the classifier by forgetting
+// rare words. Words counted less than minCount times over all
+// classes are dropped, then only the maxVocab most frequent words
+// are kept. A maxVocab of 0 means no limit. It returns the number
+// of words dropped. Once the classifier has decayed, the counts
+// are the decayed ones, as Stats reports them.
+//
+// The word totals of the classes are left untouched, so the
+// probability P(W|C_j) of the words kept does not change, and
+// dropped words are handled like words never seen before.
+func (c *Classifier) Prune(minCount, maxVocab int) int {
+	counts := make(map[string]float64)
+	for _, data := range c.datas {
+		for word, freq := range data.Freqs {
+			counts[word] += freq
+		}
+	}
+	unit := c.unitWeight()
+	for word := range counts {
+		counts[word] /= unit
+	}
+
+	drop := make(map[string]struct{})
+	keep := make([]string, 0, len(counts))
+	for word, count := range counts {
+		if count < float64(minCount) {
+			drop[word] = struct{}{}
+		} else {
+			keep = append(keep, word)
+		}
+	}
+	if maxVocab > 0 && len(keep) > maxVocab {
+		sort.Slice(keep, func(i, j int) bool {
+			if counts[keep[i]] != counts[keep[j]] {
+				return counts[keep[i]] > counts[keep[j]]
+			}
+			return keep[i] < keep[j]
+		})
+		for _, word := range keep[maxVocab:] {
+			drop[word] = struct{}{}
+		}
+	}
+	if len(drop) == 0 {
+		return 0
+	}
+
+	c.tfIdfMu.Lock()
+	defer c.tfIdfMu.Unlock()
+	for _, data := range c.datas {
+		for word := range drop {
+			delete(data.Freqs, word)
+			delete(data.FreqTfs, word)
+			delete(data.DocFreqs, word)
+			if data.TfIdfs != nil {
+				delete(data.TfIdfs, word)
+			}
+		}
+	}
+	c.cache.Store(nil)
+	return len(drop)
+}
+
+// HashFeatures enables feature hashing: every word is mapped to
+// one of the given number of buckets with goutil.StringToUint64,
+// and the classifier only stores the buckets. This caps the size
+// of the model at the cost of words sharing a bucket being
+// indistinguishable. A number of buckets of 0 disables hashing.
+//
+// Feature hashing must be chosen before training, otherwise
+// ErrNotEmpty is returned. It is saved along with the classifier.
+func (c *Classifier) HashFeatures(buckets uint64) error {
+	if c.learned > 0 {
+		return ErrNotEmpty
+	}
+	for _, data := range c.datas {
+		if data.Total > 0 {
+			return ErrNotEmpty
+		}
+	}
+	c.buckets = buckets
+	return nil
+}
+
+// HashBuckets returns the number of feature hashing buckets,
+// or 0 when feature hashing is disabled.
+func (c *Classifier) HashBuckets() uint64 {
+	return c.buckets
+}
+
+// feature returns the key the word is stored under.
+func (c *Classifier) feature(word string) string {
+	if c.buckets == 0 {
+		return word
+	}
+	return strconv.FormatUint(goutil.StringToUint64(word)%c.buckets, 36)
+}
+
+// features maps every word of the document to its key.
+func (c *Classifier) features(document []string) []string {
+	if c.buckets == 0 {
+		return document
+	}
+	keys := make([]string, len(document))
+	for i, word := range document {
+		keys[i] = c.feature(word)
+	}
+	return keys
+}
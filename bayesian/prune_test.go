@@ -0,0 +1,53 @@
+package bayesian
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall", "tall", "tall", "handsome", "rich", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "poor", "rich"}, Bad)
+
+	tall := c.datas[Good].getWordProb("tall")
+	Assert(t, c.Prune(2, 0) == 2, "handsome, bald")
+	Assert(t, c.datas[Good].getWordProb("handsome") == defaultProb, "handsome kept")
+	Assert(t, c.datas[Bad].getWordProb("bald") == defaultProb, "bald kept")
+	Assert(t, c.datas[Good].getWordProb("tall") == tall, "probability changed")
+	Assert(t, c.WordCount()[0] == 6, "total changed")
+
+	// rich: 3, tall: 3, poor: 2
+	Assert(t, c.Prune(0, 2) == 1)
+	_, ok := c.datas[Bad].Freqs["poor"]
+	Assert(t, !ok, "poor kept")
+	Assert(t, len(c.datas[Good].Freqs) == 2 && len(c.datas[Bad].Freqs) == 1)
+	Assert(t, c.Prune(0, 2) == 0)
+}
+
+func TestHashFeatures(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	Assert(t, c.HashFeatures(8) == nil)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "ugly"}, Bad)
+	Assert(t, c.HashFeatures(16) == ErrNotEmpty)
+
+	for _, data := range c.datas {
+		Assert(t, len(data.Freqs) <= 8, "too many buckets")
+	}
+	_, inx, _ := c.LogScores([]string{"tall", "rich"})
+	Assert(t, inx == 0, "good")
+	_, inx, _ = c.LogScores([]string{"poor", "ugly"})
+	Assert(t, inx == 1, "bad")
+
+	err := c.WriteToFile("hashed.ser")
+	Assert(t, err == nil, err)
+	defer os.Remove("hashed.ser")
+	d, err := NewClassifierFromFile("hashed.ser")
+	Assert(t, err == nil, err)
+	Assert(t, d.HashBuckets() == 8)
+	_, inx, _ = d.LogScores([]string{"poor", "ugly"})
+	Assert(t, inx == 1, "bad")
+
+	Assert(t, c.Merge(NewClassifier(Good, Bad)) == ErrIncompatible)
+}
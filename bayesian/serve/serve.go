@@ -0,0 +1,397 @@
+// Package serve exposes a bayesian classifier over HTTP.
+//
+// The Handler serves the following endpoints, relative to where
+// it is mounted (use http.StripPrefix to mount it elsewhere than
+// the root):
+//
+//	POST /classify  {"text": "...", "top_k": 3, "min_prob": 0.6, "min_margin": 0.1}
+//	POST /explain   {"text": "...", "top": 10}
+//	POST /learn     {"class": "...", "text": "..."}
+//	GET  /stats
+//
+// Documents are given either as "text", tokenized with the
+// tokenizer of the handler, or as already tokenized "words".
+// Learning is disabled unless Options.Authorize is set.
+package serve
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XiBao/goutil/bayesian"
+)
+
+// DefaultExplainTop is the number of words per class returned by
+// /explain when the request does not say.
+const DefaultExplainTop = 10
+
+// maxBodySize is the largest request body accepted.
+const maxBodySize = 1 << 20
+
+// Options configures a Handler.
+type Options struct {
+	// Tokenizer splits the text of the requests. Defaults to
+	// bayesian.DefaultTokenizer.
+	Tokenizer bayesian.Tokenizer
+	// Authorize enables the /learn endpoint and reports whether
+	// a request may train the classifier. See BearerToken.
+	Authorize func(r *http.Request) bool
+	// ErrorLog logs failed model reloads. Defaults to the
+	// standard logger.
+	ErrorLog *log.Logger
+}
+
+// BearerToken returns an Authorize function accepting the
+// requests carrying "Authorization: Bearer <token>".
+func BearerToken(token string) func(r *http.Request) bool {
+	want := []byte("Bearer " + token)
+	return func(r *http.Request) bool {
+		got := []byte(r.Header.Get("Authorization"))
+		return token != "" && subtle.ConstantTimeCompare(got, want) == 1
+	}
+}
+
+// Handler is an http.Handler serving a classifier. The classifier
+// can be replaced at any time, atomically, with Swap or by
+// reloading its file.
+type Handler struct {
+	opts  Options
+	mux   *http.ServeMux
+	model atomic.Pointer[bayesian.Classifier]
+	// learning mutates the classifier, classifying does not
+	mu sync.RWMutex
+
+	path    string
+	reload  sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// New returns a Handler serving c.
+func New(c *bayesian.Classifier, opts Options) *Handler {
+	h := &Handler{opts: opts}
+	h.model.Store(c)
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("POST /classify", h.classify)
+	h.mux.HandleFunc("POST /explain", h.explain)
+	h.mux.HandleFunc("POST /learn", h.learn)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	return h
+}
+
+// NewFromFile returns a Handler serving the classifier saved in
+// the file, see bayesian.NewClassifierFromFile. The file can then
+// be reloaded with Reload or Watch.
+func NewFromFile(path string, opts Options) (*Handler, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := bayesian.NewClassifierFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := New(c, opts)
+	h.path, h.modTime, h.size = path, info.ModTime(), info.Size()
+	return h, nil
+}
+
+// Classifier returns the classifier currently served.
+func (h *Handler) Classifier() *bayesian.Classifier {
+	return h.model.Load()
+}
+
+// Swap atomically replaces the classifier served. It waits for
+// the documents being learned through /learn, which go to the
+// previous classifier, and the requests classifying with it.
+func (h *Handler) Swap(c *bayesian.Classifier) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.model.Store(c)
+}
+
+// Reload loads the classifier file again if it changed since it
+// was last loaded, and swaps it in. It reports whether the
+// classifier was replaced. On error the current classifier is
+// kept and the next call tries again.
+//
+// Documents learned through /learn since the last load are lost
+// when the classifier is replaced.
+func (h *Handler) Reload() (bool, error) {
+	if h.path == "" {
+		return false, errors.New("serve: handler was not created from a file")
+	}
+	h.reload.Lock()
+	defer h.reload.Unlock()
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(h.modTime) && info.Size() == h.size {
+		return false, nil
+	}
+	c, err := bayesian.NewClassifierFromFile(h.path)
+	if err != nil {
+		return false, err
+	}
+	h.Swap(c)
+	h.modTime, h.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+// Watch calls Reload every interval until ctx is done, logging
+// the failures to Options.ErrorLog.
+func (h *Handler) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.Reload(); err != nil {
+				h.logf("serve: reloading %s: %v", h.path, err)
+			}
+		}
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// document is the part of the requests holding a document.
+type document struct {
+	Text  string   `json:"text"`
+	Words []string `json:"words"`
+}
+
+func (h *Handler) words(d document) []string {
+	if d.Words != nil {
+		return d.Words
+	}
+	tokenizer := h.opts.Tokenizer
+	if tokenizer == nil {
+		tokenizer = bayesian.DefaultTokenizer
+	}
+	return tokenizer.Tokenize(d.Text)
+}
+
+type classifyRequest struct {
+	document
+	TopK      int     `json:"top_k"`
+	MinProb   float64 `json:"min_prob"`
+	MinMargin float64 `json:"min_margin"`
+}
+
+type prediction struct {
+	Class bayesian.Class `json:"class"`
+	Prob  float64        `json:"prob"`
+}
+
+type classifyResponse struct {
+	Class   bayesian.Class `json:"class"`
+	Unknown bool           `json:"unknown"`
+	Prob    float64        `json:"prob"`
+	Margin  float64        `json:"margin"`
+	Top     []prediction   `json:"top"`
+}
+
+func (h *Handler) classify(w http.ResponseWriter, r *http.Request) {
+	var req classifyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	h.mu.RLock()
+	result, err := h.Classifier().ClassifyE(h.words(req.document), bayesian.ClassifyOptions{
+		TopK:      req.TopK,
+		MinProb:   req.MinProb,
+		MinMargin: req.MinMargin,
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := classifyResponse{
+		Class:   result.Class,
+		Unknown: result.IsUnknown(),
+		Prob:    result.Prob,
+		Margin:  result.Margin,
+		Top:     make([]prediction, len(result.Top)),
+	}
+	for i, p := range result.Top {
+		resp.Top[i] = prediction{Class: p.Class, Prob: p.Prob}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type explainRequest struct {
+	document
+	Top int `json:"top"`
+}
+
+type wordContribution struct {
+	Word   string  `json:"word"`
+	Count  int     `json:"count"`
+	Weight float64 `json:"weight"`
+}
+
+type contribution struct {
+	Class bayesian.Class     `json:"class"`
+	Score float64            `json:"score"`
+	Words []wordContribution `json:"words"`
+}
+
+func (h *Handler) explain(w http.ResponseWriter, r *http.Request) {
+	var req explainRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	top := req.Top
+	if top <= 0 {
+		top = DefaultExplainTop
+	}
+	h.mu.RLock()
+	contribs, err := h.Classifier().ExplainE(h.words(req.document))
+	h.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := make([]contribution, len(contribs))
+	for i, c := range contribs {
+		words := c.Top(top)
+		resp[i] = contribution{
+			Class: c.Class,
+			Score: c.Score,
+			Words: make([]wordContribution, len(words)),
+		}
+		for j, word := range words {
+			resp[i].Words[j] = wordContribution{Word: word.Word, Count: word.Count, Weight: word.Weight}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type learnRequest struct {
+	document
+	Class bayesian.Class `json:"class"`
+}
+
+func (h *Handler) learn(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Authorize == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.opts.Authorize(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	var req learnRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	h.mu.Lock()
+	err := h.Classifier().LearnE(h.words(req.document), req.Class)
+	h.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type statsResponse struct {
+	Classes    []bayesian.Class `json:"classes"`
+	Model      string           `json:"model"`
+	TfIdf      bool             `json:"tf_idf"`
+	Learned    int              `json:"learned"`
+	Seen       int              `json:"seen"`
+	Vocabulary int              `json:"vocabulary"`
+	WordCount  []int            `json:"word_count"`
+	PerClass   []classStats     `json:"per_class"`
+}
+
+type classStats struct {
+	Class      bayesian.Class `json:"class"`
+	Documents  int            `json:"documents"`
+	Words      int            `json:"words"`
+	Vocabulary int            `json:"vocabulary"`
+	Entropy    float64        `json:"entropy"`
+	TopWords   []wordFreq     `json:"top_words"`
+}
+
+type wordFreq struct {
+	Word  string  `json:"word"`
+	Count float64 `json:"count"`
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	c := h.Classifier()
+	stats := c.Stats()
+	resp := statsResponse{
+		Classes:    c.Classes,
+		Model:      stats.Model.String(),
+		TfIdf:      stats.TfIdf,
+		Learned:    stats.Learned,
+		Seen:       stats.Seen,
+		Vocabulary: stats.Vocabulary,
+		WordCount:  c.WordCount(),
+		PerClass:   make([]classStats, len(stats.Classes)),
+	}
+	h.mu.RUnlock()
+	for i, cs := range stats.Classes {
+		words := make([]wordFreq, len(cs.TopWords))
+		for j, word := range cs.TopWords {
+			words[j] = wordFreq{Word: word.Word, Count: word.Count}
+		}
+		resp.PerClass[i] = classStats{
+			Class:      cs.Class,
+			Documents:  cs.Documents,
+			Words:      cs.Words,
+			Vocabulary: cs.Vocabulary,
+			Entropy:    cs.Entropy,
+			TopWords:   words,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) logf(format string, args ...any) {
+	if h.opts.ErrorLog != nil {
+		h.opts.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// decode reads the JSON request body into v, replying with an
+// error if it can't.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,145 @@
+package serve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XiBao/goutil/bayesian"
+)
+
+const (
+	Good bayesian.Class = "good"
+	Bad  bayesian.Class = "bad"
+)
+
+func newClassifier() *bayesian.Classifier {
+	c := bayesian.NewClassifier(Good, Bad)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"bald", "poor", "ugly"}, Bad)
+	return c
+}
+
+func do(t *testing.T, h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestClassify(t *testing.T) {
+	h := New(newClassifier(), Options{})
+	w := do(t, h, "POST", "/classify", `{"text": "Tall and rich", "top_k": 1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code %d: %s", w.Code, w.Body)
+	}
+	var resp classifyResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Class != Good || resp.Unknown || len(resp.Top) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	w = do(t, h, "POST", "/classify", `{"words": ["what"], "min_prob": 0.9}`)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Unknown {
+		t.Fatalf("expected unknown %+v", resp)
+	}
+
+	w = do(t, h, "POST", "/classify", `not json`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("code %d", w.Code)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	h := New(newClassifier(), Options{})
+	w := do(t, h, "POST", "/explain", `{"words": ["tall", "poor", "man"], "top": 1}`)
+	var resp []contribution
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body)
+	}
+	if len(resp) != 2 || resp[0].Words[0].Word != "tall" || resp[1].Words[0].Word != "poor" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestLearn(t *testing.T) {
+	h := New(newClassifier(), Options{})
+	if w := do(t, h, "POST", "/learn", `{"class": "good", "text": "kind"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("learning should be disabled, code %d", w.Code)
+	}
+
+	h = New(newClassifier(), Options{Authorize: BearerToken("secret")})
+	if w := do(t, h, "POST", "/learn", `{"class": "good", "text": "kind"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("code %d", w.Code)
+	}
+	auth := []string{"Authorization", "Bearer secret"}
+	if w := do(t, h, "POST", "/learn", `{"class": "good", "text": "kind"}`, auth...); w.Code != http.StatusNoContent {
+		t.Fatalf("code %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, h, "POST", "/learn", `{"class": "ugly", "text": "kind"}`, auth...); w.Code != http.StatusBadRequest {
+		t.Fatalf("code %d", w.Code)
+	}
+	if h.Classifier().Learned() != 3 {
+		t.Fatalf("learned %d", h.Classifier().Learned())
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := New(newClassifier(), Options{})
+	w := do(t, h, "GET", "/stats", "")
+	var resp statsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Learned != 2 || resp.Model != "multinomial" || len(resp.WordCount) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(resp.PerClass) != 2 || resp.PerClass[0].Documents != 1 || len(resp.PerClass[0].TopWords) == 0 {
+		t.Fatalf("unexpected per class stats %+v", resp.PerClass)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gob")
+	if err := newClassifier().WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewFromFile(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := h.Reload(); ok || err != nil {
+		t.Fatalf("reloaded an unchanged file: %v %v", ok, err)
+	}
+
+	c := newClassifier()
+	c.Learn([]string{"kind"}, Good)
+	if err := c.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if ok, err := h.Reload(); !ok || err != nil {
+		t.Fatalf("not reloaded: %v %v", ok, err)
+	}
+	if h.Classifier().Learned() != 3 {
+		t.Fatalf("learned %d", h.Classifier().Learned())
+	}
+
+	// a broken file keeps the current classifier
+	os.WriteFile(path, []byte("broken"), 0644)
+	if _, err := h.Reload(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if h.Classifier().Learned() != 3 {
+		t.Fatalf("learned %d", h.Classifier().Learned())
+	}
+}
@@ -0,0 +1,114 @@
+package bayesian
+
+import (
+	"math"
+	"sort"
+)
+
+// statsTopWords is the number of most frequent words per class
+// returned by Stats.
+const statsTopWords = 10
+
+// WordFreq is a word with the number of times it was learned,
+// or its current weight when the classifier decays.
+type WordFreq struct {
+	Word  string
+	Count float64
+}
+
+// ClassStats describes what a single class has learned.
+type ClassStats struct {
+	Class Class
+	// Documents is the number of documents learned.
+	Documents int
+	// Words is the number of words learned, the same as
+	// WordCount.
+	Words int
+	// Vocabulary is the number of distinct words learned.
+	Vocabulary int
+	// Entropy is the Shannon entropy, in nats, of the word
+	// distribution of the class. A low entropy means a few
+	// words make up most of the class.
+	Entropy float64
+	// TopWords holds the most frequent words, in descending
+	// order of frequency.
+	TopWords []WordFreq
+}
+
+// Stats describes the whole classifier.
+type Stats struct {
+	Model   Model
+	TfIdf   bool
+	Learned int
+	Seen    int
+	// Vocabulary is the number of distinct words over all
+	// classes.
+	Vocabulary int
+	// Classes holds the statistics of every class, in the
+	// order of c.Classes.
+	Classes []ClassStats
+}
+
+// Stats returns statistics about what the classifier learned,
+// meant for monitoring. They are computed from the raw word
+// counts, so they do not depend on TF-IDF. With feature hashing
+// the words are the bucket keys.
+func (c *Classifier) Stats() Stats {
+	stats := Stats{
+		Model:      c.model,
+		TfIdf:      c.tfIdf,
+		Learned:    c.learned,
+		Seen:       c.Seen(),
+		Vocabulary: c.stats().vocabulary,
+		Classes:    make([]ClassStats, len(c.Classes)),
+	}
+	for index, class := range c.Classes {
+		stats.Classes[index] = c.datas[class].stats(class, c.unitWeight())
+	}
+	return stats
+}
+
+// DocumentCount returns the number of documents learned
+// per class. The index j of the count corresponds to the
+// class given by c.Classes[j].
+func (c *Classifier) DocumentCount() []int {
+	result := make([]int, len(c.Classes))
+	for index, class := range c.Classes {
+		result[index] = c.datas[class].Docs
+	}
+	return result
+}
+
+// stats computes the statistics of the class, dividing the
+// word frequencies by unit.
+func (d *classData) stats(class Class, unit float64) ClassStats {
+	s := ClassStats{
+		Class:      class,
+		Documents:  d.Docs,
+		Words:      d.Total,
+		Vocabulary: len(d.Freqs),
+	}
+	sum := float64(0)
+	words := make([]WordFreq, 0, len(d.Freqs))
+	for word, count := range d.Freqs {
+		sum += count
+		words = append(words, WordFreq{Word: word, Count: count / unit})
+	}
+	for _, w := range words {
+		if w.Count > 0 {
+			p := w.Count / sum
+			s.Entropy -= p * math.Log(p)
+		}
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+	if len(words) > statsTopWords {
+		words = words[:statsTopWords]
+	}
+	s.TopWords = words
+	return s
+}
@@ -0,0 +1,28 @@
+package bayesian
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall", "rich", "rich", "kind"}, Good)
+	c.Learn([]string{"rich"}, Good)
+	c.Learn([]string{"poor", "ugly"}, Bad)
+
+	Assert(t, reflect.DeepEqual(c.DocumentCount(), []int{2, 1}), c.DocumentCount())
+	s := c.Stats()
+	Assert(t, s.Model == Multinomial && s.Learned == 3 && s.Vocabulary == 5, s)
+	Assert(t, len(s.Classes) == 2, s)
+
+	good := s.Classes[0]
+	Assert(t, good.Class == Good && good.Documents == 2 && good.Words == 5 && good.Vocabulary == 3, good)
+	Assert(t, reflect.DeepEqual(good.TopWords, []WordFreq{{"rich", 3}, {"kind", 1}, {"tall", 1}}), good.TopWords)
+	want := -0.6*math.Log(0.6) - 0.4*math.Log(0.2)
+	Assert(t, math.Abs(good.Entropy-want) < 1e-12, good.Entropy)
+
+	bad := s.Classes[1]
+	Assert(t, math.Abs(bad.Entropy-math.Ln2) < 1e-12, bad.Entropy)
+}
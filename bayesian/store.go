@@ -0,0 +1,218 @@
+package bayesian
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// storeVersion is the version of the directory layout written
+// by WriteToDir.
+const storeVersion = 1
+
+// manifestName is the name of the manifest of a model store.
+const manifestName = "manifest.json"
+
+// ErrIncomplete is returned when loading a model store whose
+// class files are missing or do not match its manifest, for
+// example because it is still being written.
+var ErrIncomplete = errors.New("incomplete model store")
+
+// manifest ties the class files of a model store together.
+type manifest struct {
+	Version      int               `json:"version"`
+	Model        string            `json:"model"`
+	TfIdf        bool              `json:"tf_idf"`
+	Converted    bool              `json:"converted"`
+	LegacyTfIdf  bool              `json:"legacy_tf_idf,omitempty"`
+	Buckets      uint64            `json:"buckets,omitempty"`
+	Priors       PriorSource       `json:"priors,omitempty"`
+	PriorWeights map[Class]float64 `json:"prior_weights,omitempty"`
+	Decay        float64           `json:"decay,omitempty"`
+	Learned      int               `json:"learned"`
+	Seen         int               `json:"seen"`
+	Classes      []classCounts     `json:"classes"`
+}
+
+// classCounts identifies the content of a class file.
+type classCounts struct {
+	Class     Class `json:"class"`
+	Documents int   `json:"documents"`
+	Words     int   `json:"words"`
+}
+
+// WriteToDir saves the classifier to a model store: a directory
+// holding one file per class, as written by WriteClassToFile,
+// and a manifest with the settings of the classifier and the
+// counts of every class. The directory is created if needed.
+//
+// Every file is replaced atomically and the manifest is written
+// last, so a store being written is either loaded whole or
+// rejected by NewClassifierFromDir.
+func (c *Classifier) WriteToDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	c.refreshTfIdf()
+	m := manifest{
+		Version:      storeVersion,
+		Model:        c.model.String(),
+		TfIdf:        c.tfIdf,
+		Converted:    c.DidConvertTfIdf,
+		LegacyTfIdf:  c.legacyTfIdf,
+		Buckets:      c.buckets,
+		Priors:       c.priors,
+		PriorWeights: c.weights,
+		Decay:        c.decay,
+		Learned:      c.learned,
+		Seen:         c.Seen(),
+		Classes:      make([]classCounts, len(c.Classes)),
+	}
+	for index, class := range c.Classes {
+		if err := c.WriteClassToFile(class, dir); err != nil {
+			return err
+		}
+		data := c.datas[class]
+		m.Classes[index] = classCounts{Class: class, Documents: data.Docs, Words: data.Total}
+	}
+	return writeFileAtomic(filepath.Join(dir, manifestName), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&m)
+	})
+}
+
+// NewClassifierFromDir loads a classifier saved with WriteToDir.
+// It returns ErrIncomplete if a class file is missing or does not
+// hold what the manifest says.
+func NewClassifierFromDir(dir string) (*Classifier, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestName, err)
+	}
+	if m.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported model store version %d", m.Version)
+	}
+	model, err := parseModel(m.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &serializableClassifier{
+		Classes:         make([]Class, len(m.Classes)),
+		Learned:         m.Learned,
+		Seen:            m.Seen,
+		Datas:           make(map[Class]*classData, len(m.Classes)),
+		TfIdf:           m.TfIdf,
+		DidConvertTfIdf: m.Converted,
+		LegacyTfIdf:     m.LegacyTfIdf,
+		Model:           model,
+		Buckets:         m.Buckets,
+		Priors:          m.Priors,
+		PriorWeights:    m.PriorWeights,
+		Decay:           m.Decay,
+	}
+	docs := 0
+	for index, counts := range m.Classes {
+		if _, ok := w.Datas[counts.Class]; ok {
+			return nil, fmt.Errorf("%w %q", ErrDuplicateClass, counts.Class)
+		}
+		data, err := readClassFile(dir, counts.Class)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: class %q is missing", ErrIncomplete, counts.Class)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data.Docs != counts.Documents || data.Total != counts.Words {
+			return nil, fmt.Errorf("%w: class %q does not match the manifest", ErrIncomplete, counts.Class)
+		}
+		w.Classes[index] = counts.Class
+		w.Datas[counts.Class] = data
+		docs += data.Docs
+	}
+	if len(w.Classes) < 2 {
+		return nil, ErrTooFewClasses
+	}
+	if docs > m.Learned {
+		return nil, fmt.Errorf("%w: classes hold more documents than learned", ErrIncomplete)
+	}
+	return w.classifier(), nil
+}
+
+// parseModel returns the model with the given name.
+func parseModel(name string) (Model, error) {
+	for _, m := range []Model{Multinomial, Bernoulli, Complement} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownModel, name)
+}
+
+// classFileName returns the name of the file holding the class
+// in dir. The class must be usable as a file name.
+func classFileName(dir string, class Class) (string, error) {
+	name := string(class)
+	if name == "" || name == "." || name == ".." || name == manifestName ||
+		filepath.Base(name) != name {
+		return "", fmt.Errorf("class %q can't be used as a file name", class)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// readClassFile decodes the class file of the class in dir.
+func readClassFile(dir string, class Class) (*classData, error) {
+	fileName, err := classFileName(dir, class)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := new(classData)
+	if err := gob.NewDecoder(file).Decode(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	data.init()
+	return data, nil
+}
+
+// writeFileAtomic writes a file with write by renaming a
+// temporary file over it once it is complete.
+func writeFileAtomic(name string, write func(w io.Writer) error) (err error) {
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err = write(file); err != nil {
+		return err
+	}
+	if err = file.Chmod(0644); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
@@ -0,0 +1,64 @@
+package bayesian
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModelStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "model")
+	c := NewClassifierModel(Bernoulli, Good, Bad)
+	c.Learn([]string{"tall", "handsome", "rich"}, Good)
+	c.Learn([]string{"poor"}, Bad)
+	c.Learn([]string{"ugly", "poor"}, Bad)
+	Assert(t, c.SetPriorSource(PriorUniform) == nil)
+	err := c.WriteToDir(dir)
+	Assert(t, err == nil, err)
+
+	d, err := NewClassifierFromDir(dir)
+	Assert(t, err == nil, err)
+	Assert(t, reflect.DeepEqual(d.Classes, c.Classes), d.Classes)
+	Assert(t, d.Model() == Bernoulli && d.PriorSource() == PriorUniform)
+	Assert(t, d.Learned() == 3, d.Learned())
+	Assert(t, reflect.DeepEqual(d.DocumentCount(), []int{1, 2}), d.DocumentCount())
+	want, _, _ := c.LogScores([]string{"poor", "rich"})
+	got, _, _ := d.LogScores([]string{"poor", "rich"})
+	Assert(t, reflect.DeepEqual(got, want), got, want)
+
+	// a class file that does not match the manifest
+	c.Learn([]string{"rich"}, Good)
+	Assert(t, c.WriteClassToFile(Good, dir) == nil)
+	_, err = NewClassifierFromDir(dir)
+	Assert(t, errors.Is(err, ErrIncomplete), err)
+
+	Assert(t, os.Remove(filepath.Join(dir, string(Good))) == nil)
+	_, err = NewClassifierFromDir(dir)
+	Assert(t, errors.Is(err, ErrIncomplete), err)
+
+	_, err = NewClassifierFromDir(t.TempDir())
+	Assert(t, errors.Is(err, os.ErrNotExist), err)
+}
+
+func TestReadClassFromFileReplaces(t *testing.T) {
+	dir := t.TempDir()
+	c := NewClassifier(Good, Bad)
+	c.Learn([]string{"tall"}, Good)
+	c.Learn([]string{"rich"}, Good)
+	Assert(t, c.WriteClassesToFile(dir) == nil)
+
+	d := NewClassifier(Good, Bad)
+	d.Learn([]string{"kind"}, Good)
+	d.Learn([]string{"poor"}, Bad)
+	Assert(t, d.ReadClassFromFile(Good, dir) == nil)
+	Assert(t, d.Learned() == 3, d.Learned())
+	Assert(t, d.ReadClassFromFile(Good, dir) == nil)
+	Assert(t, d.Learned() == 3, d.Learned())
+
+	e := NewClassifier("a/b", Bad)
+	Assert(t, e.WriteClassesToFile(dir) != nil, "class names must be file names")
+	entries, _ := os.ReadDir(dir)
+	Assert(t, len(entries) == 2, "no temporary file left behind")
+}
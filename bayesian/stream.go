@@ -0,0 +1,216 @@
+package bayesian
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Format is the encoding of the labeled documents read by
+// LearnFrom.
+type Format int
+
+const (
+	// TSV holds one document per line: the class, a tab, and
+	// the text of the document.
+	TSV Format = iota
+	// JSONLines holds one JSON object per line, either with
+	// the text of the document,
+	//
+	//	{"class": "phone", "text": "华为手机 5G"}
+	//
+	// or with the already tokenized words:
+	//
+	//	{"class": "phone", "words": ["华为", "手机", "5G"]}
+	JSONLines
+)
+
+// DefaultProgressEvery is how often, in documents, LearnFrom
+// reports its progress when LearnOptions.ProgressEvery is not
+// set.
+const DefaultProgressEvery = 10000
+
+// maxLineSize is the longest line LearnFrom accepts.
+const maxLineSize = 64 << 20
+
+// LearnOptions configures LearnFrom.
+type LearnOptions struct {
+	// Tokenizer splits the text of the documents. Defaults to
+	// DefaultTokenizer. It must be safe for concurrent use.
+	Tokenizer Tokenizer
+	// Workers is the number of goroutines tokenizing and
+	// learning documents. Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// Progress, when set, is called with the number of
+	// documents learned so far, every ProgressEvery documents
+	// and once at the end, unless that count was just reported.
+	Progress func(docs int)
+	// ProgressEvery defaults to DefaultProgressEvery.
+	ProgressEvery int
+}
+
+// labeledDocument is a single line read by LearnFrom.
+type labeledDocument struct {
+	Class Class    `json:"class"`
+	Text  string   `json:"text"`
+	Words []string `json:"words"`
+	line  int
+}
+
+// LearnFrom streams labeled documents from r and learns them,
+// without loading them all into memory. The documents are split
+// among workers that learn into private classifiers, which are
+// merged into c once the whole input was read. If reading,
+// parsing or learning fails, or ctx is done, nothing is learned
+// and the error is returned.
+//
+// It returns the number of documents learned. A nil opts uses
+// the defaults.
+func (c *Classifier) LearnFrom(ctx context.Context, r io.Reader, format Format, opts *LearnOptions) (int, error) {
+	if opts == nil {
+		opts = &LearnOptions{}
+	}
+	if format != TSV && format != JSONLines {
+		return 0, fmt.Errorf("unknown format %d", format)
+	}
+	if c.legacyTfIdf {
+		return 0, ErrLegacyTfIdf
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	every := opts.ProgressEvery
+	if every <= 0 {
+		every = DefaultProgressEvery
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var (
+		progressMu sync.Mutex
+		learned    int
+	)
+	report := func() {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		learned++
+		if opts.Progress != nil && learned%every == 0 {
+			opts.Progress(learned)
+		}
+	}
+
+	docs := make(chan labeledDocument, workers*64)
+	shards := make([]*Classifier, workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shard := c.emptyCopy()
+		shards[i] = shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range docs {
+				words := doc.Words
+				if words == nil {
+					words = tokenize(doc.Text, opts.Tokenizer)
+				}
+				if err := shard.LearnE(words, doc.Class); err != nil {
+					fail(fmt.Errorf("line %d: %w", doc.line, err))
+					return
+				}
+				report()
+			}
+		}()
+	}
+
+	if err := readDocuments(ctx, r, format, docs); err != nil {
+		fail(err)
+	}
+	close(docs)
+	wg.Wait()
+
+	if firstErr == nil {
+		// the parent context may be done even though
+		// every line was read
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if err := c.Merge(shards...); err != nil {
+		return 0, err
+	}
+	if opts.Progress != nil && learned%every != 0 {
+		opts.Progress(learned)
+	}
+	return learned, nil
+}
+
+// readDocuments parses r and sends the documents to docs until
+// the input ends or ctx is done.
+func readDocuments(ctx context.Context, r io.Reader, format Format, docs chan<- labeledDocument) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		doc := labeledDocument{line: line}
+		switch format {
+		case TSV:
+			class, body, ok := strings.Cut(text, "\t")
+			if !ok {
+				return fmt.Errorf("line %d: missing tab", line)
+			}
+			doc.Class, doc.Text = Class(class), body
+		case JSONLines:
+			if err := json.Unmarshal([]byte(text), &doc); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		select {
+		case docs <- doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// emptyCopy returns a classifier with the same classes and
+// settings as c that has not learned anything. It does not
+// decay: documents learned in a batch all keep the same weight.
+func (c *Classifier) emptyCopy() *Classifier {
+	d := &Classifier{
+		Classes: append([]Class(nil), c.Classes...),
+		datas:   make(map[Class]*classData, len(c.Classes)),
+		tfIdf:   c.tfIdf,
+		model:   c.model,
+		buckets: c.buckets,
+		priors:  c.priors,
+		weights: c.weights,
+	}
+	for _, class := range c.Classes {
+		d.datas[class] = newClassData()
+	}
+	return d
+}
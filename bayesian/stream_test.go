@@ -0,0 +1,82 @@
+package bayesian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLearnFromTSV(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "good\ttall handsome rich %d\n", i)
+		fmt.Fprintf(&sb, "bad\tbald poor ugly\n\n")
+	}
+	var progress []int
+	c := NewClassifier(Good, Bad)
+	n, err := c.LearnFrom(context.Background(), strings.NewReader(sb.String()), TSV, &LearnOptions{
+		Tokenizer:     SplitTokenizer{},
+		Workers:       4,
+		Progress:      func(docs int) { progress = append(progress, docs) },
+		ProgressEvery: 50,
+	})
+	Assert(t, err == nil, err)
+	Assert(t, n == 200 && c.Learned() == 200, n)
+	Assert(t, fmt.Sprint(progress) == "[50 100 150 200]", progress)
+
+	// same model as learning the documents one by one
+	d := NewClassifier(Good, Bad)
+	for i := 0; i < 100; i++ {
+		d.Learn(strings.Fields(fmt.Sprintf("tall handsome rich %d", i)), Good)
+		d.Learn([]string{"bald", "poor", "ugly"}, Bad)
+	}
+	Assert(t, fmt.Sprint(c.WordCount()) == fmt.Sprint(d.WordCount()), c.WordCount())
+	got, _, _ := c.LogScores([]string{"tall", "poor", "7"})
+	want, _, _ := d.LogScores([]string{"tall", "poor", "7"})
+	for i := range want {
+		Assert(t, math.Abs(got[i]-want[i]) < 1e-9, got, want)
+	}
+
+	progress = nil
+	_, err = NewClassifier(Good, Bad).LearnFrom(context.Background(), strings.NewReader(sb.String()), TSV, &LearnOptions{
+		Progress:      func(docs int) { progress = append(progress, docs) },
+		ProgressEvery: 60,
+	})
+	Assert(t, err == nil, err)
+	Assert(t, fmt.Sprint(progress) == "[60 120 180 200]", progress)
+}
+
+func TestLearnFromJSONLines(t *testing.T) {
+	input := `{"class": "good", "text": "Tall, handsome"}
+{"class": "bad", "words": ["bald", "poor"]}
+`
+	c := NewClassifier(Good, Bad)
+	n, err := c.LearnFrom(context.Background(), strings.NewReader(input), JSONLines, nil)
+	Assert(t, err == nil, err)
+	Assert(t, n == 2, n)
+	Assert(t, c.datas[Good].Freqs["tall"] == 1, "default tokenizer")
+	Assert(t, c.datas[Bad].Freqs["poor"] == 1, "words")
+}
+
+func TestLearnFromErrors(t *testing.T) {
+	c := NewClassifier(Good, Bad)
+	_, err := c.LearnFrom(context.Background(), strings.NewReader("good\ttall\nugly\tbald\n"), TSV, nil)
+	Assert(t, errors.Is(err, ErrUnknownClass), err)
+	Assert(t, strings.HasPrefix(err.Error(), "line 2:"), err)
+	Assert(t, c.Learned() == 0, "partially learned")
+
+	_, err = c.LearnFrom(context.Background(), strings.NewReader("good tall\n"), TSV, nil)
+	Assert(t, err != nil && strings.Contains(err.Error(), "missing tab"), err)
+
+	_, err = c.LearnFrom(context.Background(), strings.NewReader("{\n"), JSONLines, nil)
+	Assert(t, err != nil, "bad json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.LearnFrom(ctx, strings.NewReader("good\ttall\n"), TSV, nil)
+	Assert(t, errors.Is(err, context.Canceled), err)
+	Assert(t, c.Learned() == 0, "learned after cancel")
+}
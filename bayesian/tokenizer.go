@@ -0,0 +1,218 @@
+package bayesian
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultTokenizer is used by LearnText and ClassifyText when
+// no tokenizer is given. It normalises the text and produces
+// unigrams and bigrams for Chinese (and other CJK) runs, and
+// whole words for everything else.
+var DefaultTokenizer Tokenizer = NewPipeline(NGramTokenizer{MinN: 1, MaxN: 2}, Normalizer{})
+
+// Tokenizer splits raw text into the words that are fed to
+// Learn and LogScores.
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// TokenizerFunc adapts an ordinary function to the Tokenizer
+// interface.
+type TokenizerFunc func(text string) []string
+
+// Tokenize calls f(text).
+func (f TokenizerFunc) Tokenize(text string) []string {
+	return f(text)
+}
+
+// Filter transforms a list of tokens, for example by dropping
+// stop words or normalising them.
+type Filter interface {
+	Filter(tokens []string) []string
+}
+
+// FilterFunc adapts an ordinary function to the Filter
+// interface.
+type FilterFunc func(tokens []string) []string
+
+// Filter calls f(tokens).
+func (f FilterFunc) Filter(tokens []string) []string {
+	return f(tokens)
+}
+
+// Pipeline is a Tokenizer that splits the text with its
+// Tokenizer and then runs the tokens through every filter,
+// in order.
+type Pipeline struct {
+	Tokenizer Tokenizer
+	Filters   []Filter
+}
+
+// NewPipeline returns a pipeline running the given tokenizer
+// followed by the given filters.
+func NewPipeline(tokenizer Tokenizer, filters ...Filter) *Pipeline {
+	return &Pipeline{
+		Tokenizer: tokenizer,
+		Filters:   filters,
+	}
+}
+
+// Tokenize implements the Tokenizer interface.
+func (p *Pipeline) Tokenize(text string) []string {
+	tokens := p.Tokenizer.Tokenize(text)
+	for _, f := range p.Filters {
+		tokens = f.Filter(tokens)
+	}
+	return tokens
+}
+
+// SplitTokenizer splits text on whitespace and punctuation,
+// full-width punctuation included. Runs of CJK characters are
+// kept as single tokens.
+type SplitTokenizer struct{}
+
+// Tokenize implements the Tokenizer interface.
+func (SplitTokenizer) Tokenize(text string) []string {
+	return strings.FieldsFunc(text, isSeparator)
+}
+
+// NGramTokenizer splits text like SplitTokenizer and then
+// breaks every run of CJK characters into overlapping rune
+// n-grams of MinN up to MaxN runes. Runs shorter than MinN are
+// kept whole. Other words (latin letters, digits) are not
+// broken up.
+//
+// The zero value produces unigrams only.
+type NGramTokenizer struct {
+	MinN int
+	MaxN int
+}
+
+// Tokenize implements the Tokenizer interface.
+func (t NGramTokenizer) Tokenize(text string) (tokens []string) {
+	minN, maxN := t.MinN, t.MaxN
+	if minN < 1 {
+		minN = 1
+	}
+	if maxN < minN {
+		maxN = minN
+	}
+	for _, field := range strings.FieldsFunc(text, isSeparator) {
+		runes := []rune(field)
+		start := 0
+		for start < len(runes) {
+			cjk := isCJK(runes[start])
+			end := start + 1
+			for end < len(runes) && isCJK(runes[end]) == cjk {
+				end++
+			}
+			run := runes[start:end]
+			if !cjk || len(run) < minN {
+				tokens = append(tokens, string(run))
+			} else {
+				for n := minN; n <= maxN && n <= len(run); n++ {
+					for i := 0; i+n <= len(run); i++ {
+						tokens = append(tokens, string(run[i:i+n]))
+					}
+				}
+			}
+			start = end
+		}
+	}
+	return tokens
+}
+
+// StopWords is a Filter that drops the words it contains.
+type StopWords map[string]struct{}
+
+// NewStopWords returns a stop word filter for the given words.
+func NewStopWords(words ...string) StopWords {
+	s := make(StopWords, len(words))
+	for _, word := range words {
+		s[word] = struct{}{}
+	}
+	return s
+}
+
+// Filter implements the Filter interface.
+func (s StopWords) Filter(tokens []string) []string {
+	ret := tokens[:0]
+	for _, token := range tokens {
+		if _, ok := s[token]; !ok {
+			ret = append(ret, token)
+		}
+	}
+	return ret
+}
+
+// Normalizer is a Filter that lowercases tokens and folds
+// full-width ASCII variants (ＡＢＣ１２３) to their normal width.
+type Normalizer struct{}
+
+// Filter implements the Filter interface.
+func (Normalizer) Filter(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = Normalize(token)
+	}
+	return tokens
+}
+
+// Normalize lowercases s and folds full-width ASCII variants
+// and the ideographic space to their normal width.
+func Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xfee0
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// LearnText tokenizes text and learns it as a document of the
+// given class. A nil tokenizer means DefaultTokenizer.
+func (c *Classifier) LearnText(text string, which Class, tokenizer Tokenizer) {
+	c.Learn(tokenize(text, tokenizer), which)
+}
+
+// LearnTextE works the same as LearnText, but returns
+// ErrUnknownClass instead of panicking.
+func (c *Classifier) LearnTextE(text string, which Class, tokenizer Tokenizer) error {
+	return c.LearnE(tokenize(text, tokenizer), which)
+}
+
+// ClassifyText tokenizes text and returns its LogScores. A nil
+// tokenizer means DefaultTokenizer.
+func (c *Classifier) ClassifyText(text string, tokenizer Tokenizer) (scores []float64, inx int, strict bool) {
+	return c.LogScores(tokenize(text, tokenizer))
+}
+
+// ClassifyTextE works the same as ClassifyText, but returns
+// ErrNotConverted instead of panicking.
+func (c *Classifier) ClassifyTextE(text string, tokenizer Tokenizer) (scores []float64, inx int, strict bool, err error) {
+	return c.LogScoresE(tokenize(text, tokenizer))
+}
+
+func tokenize(text string, tokenizer Tokenizer) []string {
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer
+	}
+	return tokenizer.Tokenize(text)
+}
+
+// isSeparator reports whether r separates words.
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+}
+
+// isCJK reports whether r is written without spaces between
+// words and therefore needs n-gram segmentation.
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,46 @@
+package bayesian
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTokenizer(t *testing.T) {
+	tokens := SplitTokenizer{}.Tokenize("Apple iPhone15，全新 正品!  (128G)")
+	want := []string{"Apple", "iPhone15", "全新", "正品", "128G"}
+	Assert(t, reflect.DeepEqual(tokens, want), tokens)
+}
+
+func TestNGramTokenizer(t *testing.T) {
+	tokens := NGramTokenizer{MinN: 1, MaxN: 2}.Tokenize("华为手机 mate60")
+	want := []string{"华", "为", "手", "机", "华为", "为手", "手机", "mate60"}
+	Assert(t, reflect.DeepEqual(tokens, want), tokens)
+
+	tokens = NGramTokenizer{MinN: 2, MaxN: 2}.Tokenize("包 连衣裙ABC")
+	want = []string{"包", "连衣", "衣裙", "ABC"}
+	Assert(t, reflect.DeepEqual(tokens, want), tokens)
+}
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(SplitTokenizer{}, Normalizer{}, NewStopWords("the", "包邮"))
+	tokens := p.Tokenize("ＴＨＥ　Ｎｉｋｅ　鞋，包邮！")
+	want := []string{"nike", "鞋"}
+	Assert(t, reflect.DeepEqual(tokens, want), tokens)
+}
+
+func TestLearnText(t *testing.T) {
+	const (
+		Phone Class = "phone"
+		Dress Class = "dress"
+	)
+	c := NewClassifier(Phone, Dress)
+	c.LearnText("华为手机 5G 全网通", Phone, nil)
+	c.LearnText("苹果手机 iPhone", Phone, nil)
+	c.LearnText("夏季连衣裙 雪纺", Dress, nil)
+	c.LearnText("碎花连衣裙 长裙", Dress, nil)
+
+	_, inx, strict := c.ClassifyText("小米手机", nil)
+	Assert(t, inx == 0 && strict, "phone")
+	_, inx, strict = c.ClassifyText("白色长裙", nil)
+	Assert(t, inx == 1 && strict, "dress")
+}
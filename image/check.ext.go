@@ -2,30 +2,35 @@ package image
 
 import (
 	"mime/multipart"
-
-	"github.com/h2non/filetype"
 )
 
+// CheckExtension returns the extension of the type of the uploaded
+// file, as told by its magic number, or "jpg" when it is unknown.
+// See SniffUpload to reject unknown or spoofed files instead.
 func CheckExtension(file *multipart.FileHeader) (string, error) {
 	src, err := file.Open()
 	if err != nil {
 		return "jpg", err
 	}
 	defer src.Close()
-	head := make([]byte, 261)
-	src.Read(head)
-	kind, _ := filetype.Match(head)
-	if kind == filetype.Unknown {
-		return "jpg", nil
+	s, err := Sniff(src)
+	if err != nil {
+		return "jpg", err
 	}
-	return kind.Extension, nil
+	return s.extensionOr("jpg"), nil
 }
 
+// CheckExtensionWithBytes works like CheckExtension on the start
+// of a file. data may be of any length.
 func CheckExtensionWithBytes(data []byte) (string, error) {
-	head := data[0:261]
-	kind, _ := filetype.Match(head)
-	if kind == filetype.Unknown {
-		return "jpg", nil
+	return SniffBytes(data).extensionOr("jpg"), nil
+}
+
+// extensionOr returns the extension of a type known from its magic
+// number, or def.
+func (s Sniffed) extensionOr(def string) string {
+	if s.Confidence != ConfidenceHigh {
+		return def
 	}
-	return kind.Extension, nil
+	return s.Extension
 }
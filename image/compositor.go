@@ -0,0 +1,142 @@
+package image
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"iter"
+)
+
+// Compositor renders the frames of an animated GIF as they are
+// displayed: every frame is drawn at its offset over what the
+// previous ones left on the canvas, after their disposal method
+// was applied. Like browsers do, gif.DisposalBackground clears to
+// transparent rather than to the background color.
+type Compositor struct {
+	g      *gif.GIF
+	canvas *image.RGBA
+	saved  *image.RGBA // canvas to restore for gif.DisposalPrevious
+	next   int         // index of the next frame to draw
+	// the previous frame was drawn but not disposed of yet
+	pending bool
+}
+
+// NewCompositor returns a Compositor for g, positioned before its
+// first frame.
+func NewCompositor(g *gif.GIF) *Compositor {
+	return &Compositor{g: g, canvas: image.NewRGBA(CanvasBounds(g))}
+}
+
+// CanvasBounds returns the bounds of the canvas of g, which only
+// the frames tell when g.Config is not set.
+func CanvasBounds(g *gif.GIF) image.Rectangle {
+	if g.Config.Width != 0 || g.Config.Height != 0 {
+		return image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	}
+	size := image.Point{}
+	for _, img := range g.Image {
+		size.X = max(size.X, img.Rect.Max.X)
+		size.Y = max(size.Y, img.Rect.Max.Y)
+	}
+	return image.Rectangle{Max: size}
+}
+
+// Bounds returns the bounds of the canvas.
+func (c *Compositor) Bounds() image.Rectangle {
+	return c.canvas.Rect
+}
+
+// Index returns the index of the next frame Next draws.
+func (c *Compositor) Index() int {
+	return c.next
+}
+
+// Before returns the canvas the next frame will be drawn on, that
+// is with the previous frame disposed of. The canvas is owned by
+// the compositor and changes with every frame.
+func (c *Compositor) Before() *image.RGBA {
+	if c.pending {
+		c.dispose(c.g.Image[c.next-1], c.disposal(c.next-1))
+		c.pending = false
+	}
+	return c.canvas
+}
+
+// Next draws the next frame and returns the canvas as displayed,
+// or false after the last frame. The canvas is owned by the
+// compositor and changes with every frame.
+func (c *Compositor) Next() (*image.RGBA, bool) {
+	if c.next >= len(c.g.Image) {
+		return nil, false
+	}
+	c.Before()
+	img := c.g.Image[c.next]
+	if c.disposal(c.next) == gif.DisposalPrevious {
+		if c.saved == nil {
+			c.saved = image.NewRGBA(c.canvas.Rect)
+		}
+		copy(c.saved.Pix, c.canvas.Pix)
+	}
+	draw.Draw(c.canvas, img.Rect, img, img.Rect.Min, draw.Over)
+	c.next++
+	c.pending = true
+	return c.canvas, true
+}
+
+// Frames returns an iterator over the remaining frames, yielding
+// their index and the canvas as displayed. The canvas is reused:
+// clone it to keep it past an iteration.
+func (c *Compositor) Frames() iter.Seq2[int, *image.RGBA] {
+	return func(yield func(int, *image.RGBA) bool) {
+		for {
+			i := c.next
+			canvas, ok := c.Next()
+			if !ok || !yield(i, canvas) {
+				return
+			}
+		}
+	}
+}
+
+// disposal returns the disposal method of the i-th frame.
+func (c *Compositor) disposal(i int) byte {
+	if i < len(c.g.Disposal) {
+		return c.g.Disposal[i]
+	}
+	return gif.DisposalNone
+}
+
+// dispose applies the disposal method of the frame last drawn.
+func (c *Compositor) dispose(img *image.Paletted, disposal byte) {
+	switch disposal {
+	case gif.DisposalBackground:
+		draw.Draw(c.canvas, img.Rect, image.Transparent, image.Point{}, draw.Src)
+	case gif.DisposalPrevious:
+		copy(c.canvas.Pix, c.saved.Pix)
+	}
+}
+
+// RenderFrames returns every frame of g as displayed.
+func RenderFrames(g *gif.GIF) []*image.RGBA {
+	frames := make([]*image.RGBA, 0, len(g.Image))
+	for _, canvas := range NewCompositor(g).Frames() {
+		frames = append(frames, cloneRGBA(canvas))
+	}
+	return frames
+}
+
+// Poster returns the first frame of g as displayed, for use as a
+// still preview. It returns nil if g has no frames.
+func Poster(g *gif.GIF) *image.RGBA {
+	canvas, ok := NewCompositor(g).Next()
+	if !ok {
+		return nil
+	}
+	return canvas
+}
+
+func cloneRGBA(m *image.RGBA) *image.RGBA {
+	c := image.NewRGBA(m.Rect)
+	copy(c.Pix, m.Pix)
+	return c
+}
@@ -0,0 +1,87 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestCompositor(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	palette := color.Palette{color.Transparent, red, blue}
+	frame := func(r image.Rectangle, index uint8) *image.Paletted {
+		m := image.NewPaletted(r, palette)
+		for i := range m.Pix {
+			m.Pix[i] = index
+		}
+		return m
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(image.Rect(0, 0, 4, 4), 1),
+			frame(image.Rect(1, 1, 3, 3), 2), // restored to red
+			frame(image.Rect(2, 2, 4, 4), 2), // cleared
+			frame(image.Rect(0, 0, 1, 1), 0),
+		},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 4, Height: 4},
+	}
+
+	want := []map[image.Point]color.RGBA{
+		{{0, 0}: red, {1, 1}: red, {3, 3}: red},
+		{{0, 0}: red, {1, 1}: blue, {2, 2}: blue, {3, 3}: red},
+		{{0, 0}: red, {1, 1}: red, {2, 2}: blue, {3, 3}: blue},
+		{{0, 0}: red, {1, 1}: red, {2, 2}: {}, {3, 3}: {}},
+	}
+	frames := RenderFrames(g)
+	if len(frames) != len(want) {
+		t.Fatalf("%d frames, want %d", len(frames), len(want))
+	}
+	for i, m := range frames {
+		if m.Rect != image.Rect(0, 0, 4, 4) {
+			t.Errorf("frame %d: bounds %v", i, m.Rect)
+		}
+		for p, c := range want[i] {
+			if got := m.RGBAAt(p.X, p.Y); got != c {
+				t.Errorf("frame %d at %v: %v, want %v", i, p, got, c)
+			}
+		}
+	}
+
+	if p := Poster(g); p.RGBAAt(1, 1) != red {
+		t.Errorf("poster at (1, 1): %v, want %v", p.RGBAAt(1, 1), red)
+	}
+	c := NewCompositor(g)
+	for i := range c.Frames() {
+		if i == 1 {
+			break
+		}
+	}
+	if c.Index() != 2 {
+		t.Errorf("index %d after breaking, want 2", c.Index())
+	}
+	if got := c.Before().RGBAAt(1, 1); got != red {
+		t.Errorf("canvas before frame 2 at (1, 1): %v, want %v", got, red)
+	}
+}
+
+func TestPalettedToRGBA(t *testing.T) {
+	in := image.NewPaletted(image.Rect(10, 20, 40, 30), color.Palette{color.Black})
+	out := PalettedToRGBA(nil, in)
+	if out.Rect != in.Rect {
+		t.Fatalf("bounds %v, want %v", out.Rect, in.Rect)
+	}
+	next := image.NewPaletted(image.Rect(0, 0, 5, 5), color.Palette{color.White})
+	out = PalettedToRGBA(out, next)
+	if want := image.Rect(0, 0, 40, 30); out.Rect != want {
+		t.Fatalf("bounds %v, want %v", out.Rect, want)
+	}
+	if got := out.RGBAAt(39, 29); got != (color.RGBA{0, 0, 0, 0xff}) {
+		t.Errorf("at (39, 29): %v, want black", got)
+	}
+	if got := out.RGBAAt(0, 0); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("at (0, 0): %v, want white", got)
+	}
+}
@@ -5,10 +5,19 @@ import (
 	"image/draw"
 )
 
+// PalettedToRGBA draws in over last, and returns the result on a
+// canvas covering both. A nil last starts from a transparent canvas.
+// See Compositor to render all the frames of a GIF.
 func PalettedToRGBA(last *image.RGBA, in *image.Paletted) *image.RGBA {
-	out := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	bounds := in.Bounds()
+	if last != nil {
+		bounds = bounds.Union(last.Bounds())
+	}
+	out := image.NewRGBA(bounds)
 
-	draw.Draw(out, out.Bounds(), last, last.Rect.Min, draw.Src)
+	if last != nil {
+		draw.Draw(out, last.Bounds(), last, last.Rect.Min, draw.Src)
+	}
 	draw.Draw(out, in.Bounds(), in, in.Rect.Min, draw.Over)
 
 	return out
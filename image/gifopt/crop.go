@@ -0,0 +1,107 @@
+package gifopt
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+)
+
+// CropFrames shrinks every frame to the bounding rectangle of its
+// opaque pixels and moves the frame to that offset, which does
+// not change how the GIF is displayed. Run after
+// InterframeCompress, it crops every frame to the pixels that
+// changed since the previous one.
+//
+// Frames disposed with gif.DisposalBackground are left whole,
+// since cropping them would shrink the area cleared after they
+// are displayed. Frames without any opaque pixel are replaced by
+// a single transparent pixel, keeping their delay.
+func CropFrames(g *gif.GIF) *gif.GIF {
+	if len(g.Image) == 0 {
+		return g
+	}
+	if g.Config.Width == 0 && g.Config.Height == 0 {
+		// the encoder would take the size of the
+		// first frame, once cropped
+		size := g.Image[0].Bounds().Max
+		for _, img := range g.Image[1:] {
+			size.X = max(size.X, img.Rect.Max.X)
+			size.Y = max(size.Y, img.Rect.Max.Y)
+		}
+		g.Config.Width, g.Config.Height = size.X, size.Y
+	}
+
+	for i, img := range g.Image {
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalBackground {
+			continue
+		}
+		transparent := transparentIndexes(img.Palette)
+		box := opaqueBounds(img, transparent)
+		if box.Empty() {
+			index, ok := firstTransparent(transparent)
+			if !ok {
+				// a palette without transparent color
+				// can't have an empty frame
+				continue
+			}
+			img = image.NewPaletted(image.Rectangle{Min: img.Rect.Min, Max: img.Rect.Min.Add(image.Pt(1, 1))}, img.Palette)
+			img.Pix[0] = index
+			g.Image[i] = img
+			continue
+		}
+		if box != img.Rect {
+			g.Image[i] = img.SubImage(box).(*image.Paletted)
+		}
+	}
+	return g
+}
+
+// transparentIndexes reports which colors of the palette are
+// fully transparent.
+func transparentIndexes(p []color.Color) (transparent [256]bool) {
+	for i, c := range p {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent[i] = true
+		}
+	}
+	return
+}
+
+// firstTransparent returns the first transparent index, if any.
+func firstTransparent(transparent [256]bool) (uint8, bool) {
+	for i, t := range transparent {
+		if t {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
+
+// opaqueBounds returns the bounding rectangle of the pixels of img
+// whose index isn't transparent.
+func opaqueBounds(img *image.Paletted, transparent [256]bool) image.Rectangle {
+	box := image.Rectangle{}
+	b := img.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):][:b.Dx()]
+		first, last := -1, -1
+		for x, index := range row {
+			if !transparent[index] {
+				if first < 0 {
+					first = x
+				}
+				last = x
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		r := image.Rect(b.Min.X+first, y, b.Min.X+last+1, y+1)
+		if box.Empty() {
+			box = r
+		} else {
+			box = box.Union(r)
+		}
+	}
+	return box
+}
@@ -0,0 +1,145 @@
+package gifopt
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+// render returns every frame of the GIF as displayed.
+func render(g *gif.GIF) []*image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	frames := make([]*image.RGBA, len(g.Image))
+	for i, img := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, img.Rect, img, img.Rect.Min, draw.Over)
+		frames[i] = cloneRGBA(canvas)
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+	return frames
+}
+
+func cloneRGBA(m *image.RGBA) *image.RGBA {
+	c := image.NewRGBA(m.Rect)
+	copy(c.Pix, m.Pix)
+	return c
+}
+
+func sameFrames(t *testing.T, got, want []*image.RGBA) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d frames, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i].Pix, want[i].Pix) {
+			t.Errorf("frame %d differs", i)
+		}
+	}
+}
+
+func TestCropFrames(t *testing.T) {
+	transparent := append(testPalette[:len(testPalette):len(testPalette)], image.Transparent)
+	frame := func(r image.Rectangle, dot image.Rectangle) *image.Paletted {
+		img := image.NewPaletted(r, transparent)
+		for i := range img.Pix {
+			img.Pix[i] = 4
+		}
+		draw.Draw(img, dot, image.NewUniform(testPalette[2]), image.Point{}, draw.Src)
+		return img
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(image.Rect(0, 0, 16, 16), image.Rect(0, 0, 16, 16)),
+			// a frame that doesn't start at the origin
+			frame(image.Rect(4, 4, 12, 12), image.Rect(6, 7, 8, 9)),
+			frame(image.Rect(0, 0, 16, 16), image.Rect(1, 1, 3, 3)),
+			frame(image.Rect(0, 0, 16, 16), image.Rect(10, 10, 14, 12)),
+			frame(image.Rect(2, 2, 10, 10), image.Rect(0, 0, 0, 0)),
+		},
+		Delay:    []int{1, 2, 3, 4, 5},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{Width: 16, Height: 16},
+	}
+	want := render(g)
+
+	CropFrames(g)
+	for i, r := range []image.Rectangle{
+		image.Rect(0, 0, 16, 16),
+		image.Rect(6, 7, 8, 9),
+		image.Rect(0, 0, 16, 16), // disposed to the background
+		image.Rect(10, 10, 14, 12),
+		image.Rect(2, 2, 3, 3), // empty
+	} {
+		if g.Image[i].Rect != r {
+			t.Errorf("frame %d: bounds %v, want %v", i, g.Image[i].Rect, r)
+		}
+	}
+	sameFrames(t, render(g), want)
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	d, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameFrames(t, render(d), want)
+}
+
+func TestCropFramesKeepsCanvas(t *testing.T) {
+	// a first frame with a single opaque pixel
+	g := testGIF(2, 16)
+	g.Image[0].Palette = append(testPalette[:1:1], image.Transparent)
+	for i := range g.Image[0].Pix {
+		g.Image[0].Pix[i] = 1
+	}
+	g.Image[0].Pix[5*16+5] = 0
+	CropFrames(g)
+	if g.Image[0].Rect != image.Rect(5, 5, 6, 6) {
+		t.Fatalf("bounds %v", g.Image[0].Rect)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	config, err := gif.DecodeConfig(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 16 || config.Height != 16 {
+		t.Fatalf("canvas %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestOptimizeCrop(t *testing.T) {
+	in := encode(t, testGIF(8, 64))
+	var compressed, cropped bytes.Buffer
+	if _, err := Optimize(bytes.NewReader(in), &compressed, Options{Threshold: 10}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Optimize(bytes.NewReader(in), &cropped, Options{Threshold: 10, Crop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cropped.Len() >= compressed.Len() {
+		t.Fatalf("cropping did not help: %d >= %d", cropped.Len(), compressed.Len())
+	}
+	if res.BytesOut != int64(cropped.Len()) {
+		t.Fatalf("%+v", res)
+	}
+}
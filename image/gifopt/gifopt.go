@@ -4,6 +4,9 @@ import (
 	"image"
 	"image/color"
 	"image/gif"
+	"math"
+
+	ximage "github.com/XiBao/goutil/image"
 )
 
 const (
@@ -18,56 +21,132 @@ const (
 // InterframeCompress is a lossy method of removing pixels to allowing gifs LZW
 // compression to better do its job.
 // threshold between 3 - 200
+//
+// Every frame is compared to the canvas as displayed before it, after the
+// disposal method of the previous frame was applied. Frames whose palette is
+// full and has no transparent color nor unused index are left unchanged.
+//
+// Colors are compared with RGBADistance, see InterframeCompressMetric.
 func InterframeCompress(g *gif.GIF, threshold float64) *gif.GIF {
+	return interframeCompress(g, threshold, nil)
+}
+
+// InterframeCompressMetric works like InterframeCompress, but compares colors
+// with the given metric. The threshold has the same scale for every metric:
+// pixels closer than sqrt(threshold)% of the largest distance are removed, so
+// a threshold of 100 removes the pixels closer than a tenth of it.
+func InterframeCompressMetric(g *gif.GIF, threshold float64, metric Metric) *gif.GIF {
+	if metric == nil {
+		metric = RGBADistance
+	}
+	return interframeCompress(g, threshold, metric)
+}
+
+// interframeCompress implements InterframeCompressMetric. A nil metric
+// compares the squared RGBA distance directly, which is the fastest.
+func interframeCompress(g *gif.GIF, threshold float64, metric Metric) *gif.GIF {
 	if len(g.Image) < 2 {
 		return g
 	}
-	limit := uint32(threshold * float64(MaxDistance) / 10000)
-
-	visible := image.NewRGBA(g.Image[0].Bounds())
+	var same func(c, v color.Color) bool
+	if metric == nil {
+		limit := uint32(threshold * float64(MaxDistance) / 10000)
+		same = func(c, v color.Color) bool {
+			return dist(c, v) < limit
+		}
+	} else {
+		limit := math.Sqrt(threshold) / 100
+		same = func(c, v color.Color) bool {
+			return metric(c, v) < limit
+		}
+	}
 
+	visible := ximage.NewCompositor(g)
 	for i, img := range g.Image {
-		sb := img.Bounds()
-		transInd := -1
-
-		p := color.Palette{}
-		for x, pc := range img.Palette {
-			_, _, _, pa := pc.RGBA()
-			if pa == 0 {
-				transInd = x
-			}
-			p = append(p, pc)
+		if i > 0 {
+			compressFrame(img, visible.Before(), same, metric != nil)
 		}
-		img.Palette = p
-
-		// Some strange gifs have frames that don't start at the origin…
-		for y := sb.Min.Y; y < sb.Max.Y; y++ {
-			for x := sb.Min.X; x < sb.Max.X; x++ {
-				c := img.At(x, y)
-
-				if i > 0 {
-					v := visible.At(x, y)
-					dd := dist(c, v)
-
-					if dd < limit {
-						if transInd == -1 {
-							transInd = img.Palette.Index(c)
+		visible.Next()
+	}
 
-							img.Palette[transInd] = color.Transparent
-						}
+	return g
+}
 
-						img.SetColorIndex(x, y, uint8(transInd))
-					}
+// compressFrame sets the pixels of img that look the same as the
+// visible canvas to transparent. When cache is set, the results of same
+// are remembered for every pair of colors.
+func compressFrame(img *image.Paletted, visible *image.RGBA, same func(c, v color.Color) bool, cache bool) {
+	// never change a palette shared with other frames
+	img.Palette = append(color.Palette(nil), img.Palette...)
+	transparent := transparentIndexes(img.Palette)
+
+	var (
+		replaced []int     // offsets of the pixels to make transparent
+		used     [256]bool // indexes of the pixels kept
+		cached   map[uint64]bool
+	)
+	if cache {
+		cached = make(map[uint64]bool)
+	}
+	// Some strange gifs have frames that don't start at the origin…
+	sb := img.Rect
+	for y := sb.Min.Y; y < sb.Max.Y; y++ {
+		for x := sb.Min.X; x < sb.Max.X; x++ {
+			offset := img.PixOffset(x, y)
+			index := img.Pix[offset]
+			if transparent[index] {
+				continue
+			}
+			if int(index) >= len(img.Palette) {
+				used[index] = true
+				continue
+			}
+			v := visible.RGBAAt(x, y)
+			var isSame bool
+			if cache {
+				key := uint64(index)<<32 | uint64(v.R)<<24 | uint64(v.G)<<16 | uint64(v.B)<<8 | uint64(v.A)
+				var ok bool
+				if isSame, ok = cached[key]; !ok {
+					isSame = same(img.Palette[index], v)
+					cached[key] = isSame
 				}
+			} else {
+				isSame = same(img.Palette[index], v)
+			}
+			if isSame {
+				replaced = append(replaced, offset)
+			} else {
+				used[index] = true
+			}
+		}
+	}
+	if len(replaced) == 0 {
+		return
+	}
 
-				if _, _, _, a := c.RGBA(); a != 0 {
-					visible.Set(x, y, c)
+	transInd, ok := firstTransparent(transparent)
+	if !ok {
+		switch {
+		case len(img.Palette) < 256:
+			transInd = uint8(len(img.Palette))
+			img.Palette = append(img.Palette, color.Transparent)
+		default:
+			// an index only used by the pixels replaced
+			for i := range used {
+				if !used[i] {
+					transInd, ok = uint8(i), true
+					break
 				}
 			}
+			if !ok {
+				return
+			}
+			img.Palette[transInd] = color.Transparent
 		}
 	}
-
-	return g
+	for _, offset := range replaced {
+		img.Pix[offset] = transInd
+	}
 }
 
 func dist(c color.Color, v color.Color) uint32 {
@@ -1,7 +1,12 @@
 package gifopt
 
 import (
+	"bytes"
+	"image"
 	"image/color"
+	"image/gif"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -11,3 +16,146 @@ func TestMaxDistance(t *testing.T) {
 		t.Errorf(`Dist(color.White, color.Transparent) = %#v; want %#v`, d, MaxDistance)
 	}
 }
+
+// checkGolden encodes and decodes the GIF compressed with the metric,
+// and checks that every displayed pixel is within the threshold of the
+// input. A nil metric uses InterframeCompress.
+func checkGolden(t *testing.T, g *gif.GIF, threshold float64, metric Metric) *gif.GIF {
+	t.Helper()
+	want := render(g)
+	var in bytes.Buffer
+	if err := gif.EncodeAll(&in, g); err != nil {
+		t.Fatal(err)
+	}
+	d, err := gif.DecodeAll(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric == nil {
+		InterframeCompress(d, threshold)
+	} else {
+		InterframeCompressMetric(d, threshold, metric)
+	}
+	var out bytes.Buffer
+	if err := gif.EncodeAll(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	d, err = gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := uint32(threshold * float64(MaxDistance) / 10000)
+	far := func(c, v color.Color) bool {
+		if metric == nil {
+			return dist(c, v) >= limit
+		}
+		return metric(c, v) >= math.Sqrt(threshold)/100
+	}
+	got := render(d)
+	if len(got) != len(want) {
+		t.Fatalf("%d frames, want %d", len(got), len(want))
+	}
+	for i := range want {
+		b := want[i].Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c, v := got[i].At(x, y), want[i].At(x, y); c != v && far(c, v) {
+					t.Fatalf("frame %d, pixel (%d, %d): %v, want %v", i, x, y, got[i].At(x, y), want[i].At(x, y))
+				}
+			}
+		}
+	}
+	return d
+}
+
+// randomGIF returns frames of random noise drawn from a palette
+// of slightly different colors, at random offsets.
+func randomGIF(seed int64, disposals []byte) *gif.GIF {
+	r := rand.New(rand.NewSource(seed))
+	palette := color.Palette{}
+	for i := 0; i < 8; i++ {
+		v := uint8(100 + i*2)
+		palette = append(palette, color.RGBA{v, v, uint8(i * 30), 255})
+	}
+	g := &gif.GIF{Config: image.Config{Width: 24, Height: 24, ColorModel: palette}}
+	for i := 0; i < 12; i++ {
+		rect := image.Rect(0, 0, 24, 24)
+		if i > 0 {
+			x, y := r.Intn(12), r.Intn(12)
+			rect = image.Rect(x, y, x+4+r.Intn(12), y+4+r.Intn(12))
+		}
+		img := image.NewPaletted(rect, palette)
+		for p := range img.Pix {
+			img.Pix[p] = uint8(r.Intn(len(palette)))
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 5)
+		g.Disposal = append(g.Disposal, disposals[i%len(disposals)])
+	}
+	return g
+}
+
+func TestInterframeCompressDisposal(t *testing.T) {
+	for name, disposals := range map[string][]byte{
+		"none":       {gif.DisposalNone},
+		"background": {gif.DisposalBackground},
+		"previous":   {gif.DisposalNone, gif.DisposalPrevious},
+		"mixed":      {gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious, gif.DisposalPrevious},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < 4; seed++ {
+				for _, threshold := range []float64{3, 20, 200} {
+					checkGolden(t, randomGIF(seed, disposals), threshold, nil)
+				}
+			}
+		})
+	}
+}
+
+func TestInterframeCompressFullPalette(t *testing.T) {
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.RGBA{uint8(i), uint8(i), uint8(i), 255}
+	}
+	frame := func(fill func(i int) uint8) *image.Paletted {
+		img := image.NewPaletted(image.Rect(0, 0, 32, 16), palette)
+		for i := range img.Pix {
+			img.Pix[i] = fill(i)
+		}
+		return img
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(func(i int) uint8 { return uint8(i) }),
+			// index 7 is only used where the previous frame has 7
+			frame(func(i int) uint8 {
+				switch {
+				case uint8(i) == 7:
+					return 7
+				case uint8(i+128) == 7:
+					return 8
+				}
+				return uint8(i + 128)
+			}),
+			// every index is used by a changed pixel
+			frame(func(i int) uint8 {
+				if i < 10 {
+					return uint8(i + 128)
+				}
+				return uint8(i + 64)
+			}),
+		},
+		Delay:  []int{1, 1, 1},
+		Config: image.Config{Width: 32, Height: 16},
+	}
+	d := checkGolden(t, g, 3, nil)
+	if _, _, _, a := d.Image[1].Palette[7].RGBA(); a != 0 {
+		t.Errorf("the unused index was not made transparent")
+	}
+	for i, c := range d.Image[2].Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			t.Errorf("index %d made transparent in a frame using every index", i)
+		}
+	}
+}
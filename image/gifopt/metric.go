@@ -0,0 +1,169 @@
+package gifopt
+
+import (
+	"image/color"
+	"math"
+)
+
+// Metric returns how different two colors look, from 0 for the
+// same color to 1 for the most different ones. Colors whose
+// alpha differ are at least as far apart as their alpha values,
+// so that a transparent color is at distance 1 from any opaque
+// one.
+//
+// The threshold of InterframeCompressMetric does not depend on
+// the metric: a threshold t removes the pixels closer than
+// sqrt(t)% of the largest distance to what is already displayed.
+type Metric func(a, b color.Color) float64
+
+// RGBADistance is the Euclidean distance between the RGBA
+// components of the colors, the metric of InterframeCompress. It
+// is cheap, but does not match human perception: changes in dark
+// colors count as much as changes in light ones.
+func RGBADistance(a, b color.Color) float64 {
+	return math.Sqrt(float64(dist(a, b)) / MaxDistance)
+}
+
+// Redmean is the Euclidean distance between the RGB components of
+// the colors, weighted by how sensitive the eye is to each of
+// them depending on the amount of red. It is nearly as cheap as
+// RGBADistance and much closer to human perception.
+func Redmean(a, b color.Color) float64 {
+	return withAlpha(a, b, func(a, b color.NRGBA) float64 {
+		rmean := (float64(a.R) + float64(b.R)) / 2
+		dr := float64(a.R) - float64(b.R)
+		dg := float64(a.G) - float64(b.G)
+		db := float64(a.B) - float64(b.B)
+		d := math.Sqrt((2+rmean/256)*dr*dr + 4*dg*dg + (2+(255-rmean)/256)*db*db)
+		// the distance between black and white
+		return d / (255 * math.Sqrt(2+127.5/256+4+2+127.5/256))
+	})
+}
+
+// CIE76 is the Euclidean distance between the colors in the
+// CIELAB color space, ΔE*ab, divided by 100, the distance between
+// black and white. A ΔE*ab of 2.3, a threshold of about 5, is
+// commonly taken as the smallest noticeable difference.
+func CIE76(a, b color.Color) float64 {
+	return withAlpha(a, b, func(a, b color.NRGBA) float64 {
+		l1, l2 := toLab(a), toLab(b)
+		return math.Sqrt(sq(l1.l-l2.l)+sq(l1.a-l2.a)+sq(l1.b-l2.b)) / 100
+	})
+}
+
+// CIEDE2000 is the CIEDE2000 color difference, ΔE00, divided by
+// 100. It corrects the perceptual non-uniformities of CIE76, in
+// blues and saturated colors in particular, but it is the most
+// costly metric.
+func CIEDE2000(a, b color.Color) float64 {
+	return withAlpha(a, b, func(a, b color.NRGBA) float64 {
+		return ciede2000(toLab(a), toLab(b)) / 100
+	})
+}
+
+// withAlpha combines the distance between the colors, as computed
+// by d, with the difference of their alpha, and clamps it to 1.
+func withAlpha(a, b color.Color, d func(a, b color.NRGBA) float64) float64 {
+	na := color.NRGBAModel.Convert(a).(color.NRGBA)
+	nb := color.NRGBAModel.Convert(b).(color.NRGBA)
+	alpha := math.Abs(float64(na.A)-float64(nb.A)) / 255
+	if na.A == 0 || nb.A == 0 {
+		// the color of a transparent pixel doesn't matter
+		return alpha
+	}
+	return min(1, max(alpha, d(na, nb)))
+}
+
+// lab is a color in the CIELAB color space.
+type lab struct {
+	l, a, b float64
+}
+
+// toLab converts an sRGB color to CIELAB, under the D65
+// illuminant.
+func toLab(c color.NRGBA) lab {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
+
+// ciede2000 returns ΔE00 between the colors, with the parametric
+// factors kL, kC and kH set to 1.
+func ciede2000(c1, c2 lab) float64 {
+	const deg = math.Pi / 180
+	cab := (math.Hypot(c1.a, c1.b) + math.Hypot(c2.a, c2.b)) / 2
+	cab7 := math.Pow(cab, 7)
+	g := 0.5 * (1 - math.Sqrt(cab7/(cab7+math.Pow(25, 7))))
+	a1, a2 := (1+g)*c1.a, (1+g)*c2.a
+	cp1, cp2 := math.Hypot(a1, c1.b), math.Hypot(a2, c2.b)
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a) / deg
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+	hp1, hp2 := hue(c1.b, a1), hue(c2.b, a2)
+
+	dl := c2.l - c1.l
+	dc := cp2 - cp1
+	dh := 0.0
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(dh/2*deg)
+
+	lp := (c1.l + c2.l) / 2
+	cp := (cp1 + cp2) / 2
+	hp := hp1 + hp2
+	if cp1*cp2 != 0 {
+		switch {
+		case math.Abs(hp1-hp2) <= 180:
+			hp /= 2
+		case hp < 360:
+			hp = (hp + 360) / 2
+		default:
+			hp = (hp - 360) / 2
+		}
+	}
+	t := 1 - 0.17*math.Cos((hp-30)*deg) + 0.24*math.Cos(2*hp*deg) +
+		0.32*math.Cos((3*hp+6)*deg) - 0.20*math.Cos((4*hp-63)*deg)
+	dtheta := 30 * math.Exp(-sq((hp-275)/25))
+	cp7 := math.Pow(cp, 7)
+	rc := 2 * math.Sqrt(cp7/(cp7+math.Pow(25, 7)))
+	sl := 1 + 0.015*sq(lp-50)/math.Sqrt(20+sq(lp-50))
+	sc := 1 + 0.045*cp
+	sh := 1 + 0.015*cp*t
+	rt := -math.Sin(2*dtheta*deg) * rc
+
+	return math.Sqrt(sq(dl/sl) + sq(dc/sc) + sq(dH/sh) + rt*(dc/sc)*(dH/sh))
+}
+
+func sq(x float64) float64 {
+	return x * x
+}
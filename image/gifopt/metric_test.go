@@ -0,0 +1,84 @@
+package gifopt
+
+import (
+	"image/color"
+	"image/gif"
+	"math"
+	"testing"
+)
+
+func TestMetricScale(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	for name, metric := range map[string]Metric{
+		"rgba":      RGBADistance,
+		"redmean":   Redmean,
+		"cie76":     CIE76,
+		"ciede2000": CIEDE2000,
+	} {
+		if d := metric(color.White, color.White); d != 0 {
+			t.Errorf("%s: same color at %v", name, d)
+		}
+		if d := metric(color.Transparent, color.RGBA{}); d != 0 {
+			t.Errorf("%s: transparent colors at %v", name, d)
+		}
+		if d := metric(color.Transparent, color.White); math.Abs(d-1) > 1e-6 {
+			t.Errorf("%s: transparent and white at %v", name, d)
+		}
+		if d := metric(black, color.White); d < 0.8 || d > 1+1e-6 {
+			t.Errorf("%s: black and white at %v", name, d)
+		}
+		if d := metric(color.RGBA{0, 0, 255, 255}, color.RGBA{255, 255, 0, 255}); d < 0 || d > 1 {
+			t.Errorf("%s: blue and yellow at %v", name, d)
+		}
+	}
+}
+
+func TestCIEDE2000(t *testing.T) {
+	// from Sharma, Wu and Dalal, "The CIEDE2000 color-difference
+	// formula: implementation notes, supplementary test data, and
+	// mathematical observations"
+	for _, test := range []struct {
+		c1, c2 lab
+		want   float64
+	}{
+		{lab{50, 2.6772, -79.7751}, lab{50, 0, -82.7485}, 2.0425},
+		{lab{50, 0, 0}, lab{50, -1, 2}, 2.3669},
+		{lab{50, 2.5, 0}, lab{73, 25, -18}, 27.1492},
+		{lab{60.2574, -34.0099, 36.2677}, lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{lab{22.7233, 20.0904, -46.6940}, lab{23.0331, 14.9730, -42.5619}, 2.0373},
+	} {
+		if got := ciede2000(test.c1, test.c2); math.Abs(got-test.want) > 1e-4 {
+			t.Errorf("ciede2000(%v, %v) = %v, want %v", test.c1, test.c2, got, test.want)
+		}
+	}
+}
+
+func TestPerceptualMetrics(t *testing.T) {
+	// RGBA distance finds the light yellows more different than
+	// the skin tones, which is the other way around to the eye
+	yellows := [2]color.Color{color.RGBA{255, 255, 0, 255}, color.RGBA{255, 255, 40, 255}}
+	skinTones := [2]color.Color{color.RGBA{224, 172, 105, 255}, color.RGBA{224, 160, 115, 255}}
+	if RGBADistance(yellows[0], yellows[1]) <= RGBADistance(skinTones[0], skinTones[1]) {
+		t.Fatal("bad test colors")
+	}
+	for name, metric := range map[string]Metric{"cie76": CIE76, "ciede2000": CIEDE2000} {
+		if metric(yellows[0], yellows[1]) >= metric(skinTones[0], skinTones[1]) {
+			t.Errorf("%s: yellows further apart than skin tones", name)
+		}
+	}
+}
+
+func TestInterframeCompressMetric(t *testing.T) {
+	for name, metric := range map[string]Metric{
+		"rgba":      RGBADistance,
+		"redmean":   Redmean,
+		"cie76":     CIE76,
+		"ciede2000": CIEDE2000,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, threshold := range []float64{3, 20, 200} {
+				checkGolden(t, randomGIF(1, []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalBackground}), threshold, metric)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package gifopt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+	"math"
+)
+
+const (
+	// DefaultMaxBytes is the largest input Optimize accepts when
+	// Options.MaxBytes is not set.
+	DefaultMaxBytes = 32 << 20
+	// DefaultMaxFrames is the largest number of frames Optimize
+	// accepts when Options.MaxFrames is not set.
+	DefaultMaxFrames = 2000
+	// DefaultMaxDimension is the largest width and height of the
+	// canvas Optimize accepts when Options.MaxWidth or
+	// Options.MaxHeight are not set.
+	DefaultMaxDimension = 4096
+	// DefaultMaxPixels is the largest number of pixels, over all
+	// the frames, Optimize accepts when Options.MaxPixels is not
+	// set. A decoded frame takes about one byte per pixel.
+	DefaultMaxPixels = 256 << 20
+)
+
+var (
+	// ErrTooLarge is returned when the input is larger than
+	// Options.MaxBytes.
+	ErrTooLarge = errors.New("gifopt: input too large")
+	// ErrTooManyFrames is returned when the GIF has more frames
+	// than Options.MaxFrames.
+	ErrTooManyFrames = errors.New("gifopt: too many frames")
+	// ErrDimensions is returned when the canvas or a frame of
+	// the GIF is larger than Options.MaxWidth or
+	// Options.MaxHeight, or the frames hold more pixels than
+	// Options.MaxPixels.
+	ErrDimensions = errors.New("gifopt: dimensions too large")
+	// ErrTargetSize is returned when the GIF can't be made as
+	// small as Options.TargetSize.
+	ErrTargetSize = errors.New("gifopt: target size not met")
+	// ErrFormat is returned when the input is not a GIF.
+	ErrFormat = errors.New("gifopt: invalid format")
+)
+
+// Options configures Optimize. The zero value only re-encodes the
+// GIF within the default limits.
+type Options struct {
+	// Threshold is passed to InterframeCompress. Zero skips the
+	// interframe compression.
+	Threshold float64
+	// Metric compares the colors for the interframe compression.
+	// Defaults to RGBADistance.
+	Metric Metric
+	// Crop shrinks the frames to the pixels that changed, see
+	// CropFrames.
+	Crop bool
+	// Width and Height resize the canvas with Filter, see Resize.
+	// When only one of them is set, the other one keeps the
+	// aspect ratio. The output of a resized GIF may be larger
+	// than its input.
+	Width  int
+	Height int
+	Filter Filter
+	// Quantize reduces the palettes of the frames before the
+	// interframe compression, see Quantize. It is skipped when
+	// Quantize.Colors is zero.
+	Quantize QuantizeOptions
+	// Merge merges the consecutive frames that display the same
+	// canvas, see MergeIdentical.
+	Merge bool
+	// Speed, when set, scales the playback speed: 2 plays the GIF
+	// twice as fast, see ScaleDuration. MaxFPS, when set, drops
+	// frames to play at most that many per second, see DropFrames.
+	// The output of a GIF whose timing changed may be larger than
+	// its input.
+	Speed  float64
+	MaxFPS float64
+	// TargetSize, when set, is the size in bytes the output must
+	// not exceed. Optimize then tries lossier thresholds and
+	// fewer colors, with cropping, until the output is small
+	// enough, and returns ErrTargetSize if it never is.
+	TargetSize int64
+
+	// MaxBytes limits the size of the input, MaxFrames its
+	// number of frames, and MaxWidth and MaxHeight the size of
+	// its canvas and frames. MaxPixels limits the number of
+	// pixels of all the frames together, which bounds the memory
+	// used to decode them. They default to DefaultMaxBytes,
+	// DefaultMaxFrames, DefaultMaxDimension and DefaultMaxPixels.
+	// The limits are checked before anything is decoded.
+	MaxBytes  int64
+	MaxFrames int
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+}
+
+// Result describes what Optimize did.
+type Result struct {
+	// BytesIn and BytesOut are the sizes of the input and of
+	// the output.
+	BytesIn  int64
+	BytesOut int64
+	// Frames is the number of frames of the GIF, and Width and
+	// Height the size of its canvas.
+	Frames int
+	Width  int
+	Height int
+	// Optimized is true when the GIF was re-encoded, and false
+	// when the optimized GIF was not smaller than the input,
+	// which was then copied unchanged. A resized or retimed GIF
+	// is always re-encoded, even when it came out larger.
+	Optimized bool
+	// Threshold and Colors are the settings used, which differ
+	// from the options when needed to meet TargetSize.
+	Threshold float64
+	Colors    int
+}
+
+// Optimize reads a GIF from r, optimizes it as configured by
+// opts and writes it to w. Unless resized or retimed, the output
+// is never larger than the input: when optimizing does not pay
+// off, the input is written unchanged.
+//
+// The input is checked against the limits of opts before being
+// decoded, so that large uploads fail fast without exhausting
+// memory. Nothing is written to w when an error is returned
+// before encoding.
+func Optimize(r io.Reader, w io.Writer, opts Options) (Result, error) {
+	opts = opts.withDefaults()
+	in, err := io.ReadAll(io.LimitReader(r, opts.MaxBytes+1))
+	if err != nil {
+		return Result{}, err
+	}
+	if int64(len(in)) > opts.MaxBytes {
+		return Result{}, ErrTooLarge
+	}
+	res := Result{BytesIn: int64(len(in))}
+	if err := opts.check(in, &res); err != nil {
+		return res, err
+	}
+
+	resized := opts.Width > 0 || opts.Height > 0
+	// the input can't replace a resized or retimed output
+	changed := resized || opts.Speed > 0 || opts.MaxFPS > 0
+	if resized {
+		if opts.Width <= 0 {
+			opts.Width = max(1, int(math.Round(float64(opts.Height*res.Width)/float64(max(res.Height, 1)))))
+		}
+		if opts.Height <= 0 {
+			opts.Height = max(1, int(math.Round(float64(opts.Width*res.Height)/float64(max(res.Width, 1)))))
+		}
+		if opts.Width > opts.MaxWidth || opts.Height > opts.MaxHeight ||
+			int64(res.Frames)*int64(opts.Width)*int64(opts.Height) > opts.MaxPixels {
+			return res, ErrDimensions
+		}
+	}
+	// the size of the output, which may be the input itself
+	size := func(out *bytes.Buffer) int64 {
+		if changed {
+			return int64(out.Len())
+		}
+		return int64(min(out.Len(), len(in)))
+	}
+
+	out, err := opts.optimize(in)
+	if err != nil {
+		return res, err
+	}
+	res.Threshold, res.Colors = opts.Threshold, opts.Quantize.Colors
+	if opts.TargetSize > 0 {
+		// lossier and lossier, until the target is met
+		for _, step := range targetSteps {
+			if size(out) <= opts.TargetSize {
+				break
+			}
+			o := opts
+			o.Threshold = max(o.Threshold, step.threshold)
+			if o.Quantize.Colors <= 0 || o.Quantize.Colors > step.colors {
+				o.Quantize.Colors = step.colors
+			}
+			o.Crop = true
+			smaller, err := o.optimize(in)
+			if err != nil {
+				return res, err
+			}
+			if smaller.Len() < out.Len() {
+				out = smaller
+				res.Threshold, res.Colors = o.Threshold, o.Quantize.Colors
+			}
+		}
+		if size(out) > opts.TargetSize {
+			return res, ErrTargetSize
+		}
+	}
+
+	if changed || out.Len() < len(in) {
+		res.Optimized = true
+		n, err := out.WriteTo(w)
+		res.BytesOut = n
+		return res, err
+	}
+	n, err := w.Write(in)
+	res.BytesOut = int64(n)
+	return res, err
+}
+
+// targetSteps are the settings tried in turn to meet
+// Options.TargetSize.
+var targetSteps = []struct {
+	threshold float64
+	colors    int
+}{
+	{5, 256},
+	{10, 128},
+	{20, 64},
+	{40, 32},
+	{80, 16},
+}
+
+// optimize decodes the GIF, optimizes it and encodes it.
+func (opts Options) optimize(in []byte) (*bytes.Buffer, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Width > 0 && opts.Height > 0 {
+		g = Resize(g, opts.Width, opts.Height, opts.Filter)
+	}
+	if opts.Merge {
+		g = MergeIdentical(g)
+	}
+	if opts.MaxFPS > 0 {
+		g = DropFrames(g, opts.MaxFPS)
+	}
+	if opts.Speed > 0 {
+		g = ScaleDuration(g, 1/opts.Speed)
+	}
+	if opts.Quantize.Colors > 0 {
+		g = Quantize(g, opts.Quantize)
+	}
+	if opts.Threshold > 0 {
+		g = interframeCompress(g, opts.Threshold, opts.Metric)
+	}
+	if opts.Crop {
+		g = CropFrames(g)
+	}
+	out := &bytes.Buffer{}
+	if err := gif.EncodeAll(out, g); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// withDefaults returns the options with the unset limits set
+// to their defaults.
+func (opts Options) withDefaults() Options {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxFrames <= 0 {
+		opts.MaxFrames = DefaultMaxFrames
+	}
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = DefaultMaxDimension
+	}
+	if opts.MaxHeight <= 0 {
+		opts.MaxHeight = DefaultMaxDimension
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = DefaultMaxPixels
+	}
+	return opts
+}
+
+// check walks the blocks of the GIF without decoding its frames,
+// filling in the size of the canvas and the number of frames,
+// and returns an error if they exceed the limits.
+func (opts Options) check(b []byte, res *Result) error {
+	s := &blockScanner{b: b}
+	header := s.next(13)
+	if header == nil || (string(header[:6]) != "GIF87a" && string(header[:6]) != "GIF89a") {
+		return ErrFormat
+	}
+	res.Width = int(header[6]) | int(header[7])<<8
+	res.Height = int(header[8]) | int(header[9])<<8
+	if res.Width > opts.MaxWidth || res.Height > opts.MaxHeight {
+		return ErrDimensions
+	}
+	if header[10]&0x80 != 0 {
+		s.next(3 << (header[10]&0x07 + 1))
+	}
+
+	var pixels int64
+	for s.err == nil {
+		block := s.next(1)
+		if block == nil {
+			break
+		}
+		switch block[0] {
+		case 0x21: // extension
+			s.next(1)
+			s.skipSubBlocks()
+		case 0x2c: // image descriptor
+			desc := s.next(9)
+			if desc == nil {
+				break
+			}
+			res.Frames++
+			if res.Frames > opts.MaxFrames {
+				return ErrTooManyFrames
+			}
+			width := int(desc[4]) | int(desc[5])<<8
+			height := int(desc[6]) | int(desc[7])<<8
+			if width > opts.MaxWidth || height > opts.MaxHeight {
+				return ErrDimensions
+			}
+			pixels += int64(width) * int64(height)
+			if pixels > opts.MaxPixels {
+				return ErrDimensions
+			}
+			if desc[8]&0x80 != 0 {
+				s.next(3 << (desc[8]&0x07 + 1))
+			}
+			s.next(1) // LZW minimum code size
+			s.skipSubBlocks()
+		case 0x3b: // trailer
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown block 0x%02x", ErrFormat, block[0])
+		}
+	}
+	// let the decoder report truncated GIFs
+	return nil
+}
+
+// blockScanner reads the blocks of a GIF held in memory.
+type blockScanner struct {
+	b   []byte
+	err error
+}
+
+// next returns the next n bytes, or nil if there are fewer left.
+func (s *blockScanner) next(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	if len(s.b) < n {
+		s.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	p := s.b[:n]
+	s.b = s.b[n:]
+	return p
+}
+
+// skipSubBlocks skips a sequence of data sub-blocks up to its
+// terminator.
+func (s *blockScanner) skipSubBlocks() {
+	for {
+		size := s.next(1)
+		if size == nil || size[0] == 0 {
+			return
+		}
+		s.next(int(size[0]))
+	}
+}
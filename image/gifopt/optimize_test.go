@@ -0,0 +1,116 @@
+package gifopt
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+var testPalette = color.Palette{
+	color.RGBA{0, 0, 0, 255},
+	color.RGBA{255, 255, 255, 255},
+	color.RGBA{255, 0, 0, 255},
+	color.RGBA{0, 0, 255, 255},
+}
+
+// testGIF returns an animation of a red square moving over a
+// noisy background, which compresses badly unless the unchanged
+// background is made transparent.
+func testGIF(frames, size int) *gif.GIF {
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, size, size), testPalette)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				img.SetColorIndex(x, y, uint8((x*7+y*13+x*y)%2))
+			}
+		}
+		for y := 0; y < size/4; y++ {
+			for x := i; x < i+size/4 && x < size; x++ {
+				img.SetColorIndex(x, y+size/2, 2)
+			}
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+	return g
+}
+
+func encode(t *testing.T, g *gif.GIF) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOptimize(t *testing.T) {
+	in := encode(t, testGIF(8, 64))
+	var out bytes.Buffer
+	res, err := Optimize(bytes.NewReader(in), &out, Options{Threshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.BytesIn != int64(len(in)) || res.BytesOut != int64(out.Len()) {
+		t.Fatalf("sizes %+v, want %d and %d", res, len(in), out.Len())
+	}
+	if !res.Optimized || res.BytesOut >= res.BytesIn {
+		t.Fatalf("not optimized: %+v", res)
+	}
+	if res.Frames != 8 || res.Width != 64 || res.Height != 64 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	g, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 8 {
+		t.Fatalf("%d frames", len(g.Image))
+	}
+}
+
+func TestOptimizeNotSmaller(t *testing.T) {
+	in := encode(t, testGIF(1, 8))
+	var out bytes.Buffer
+	res, err := Optimize(bytes.NewReader(in), &out, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Optimized || !bytes.Equal(out.Bytes(), in) {
+		t.Fatalf("input not copied: %+v", res)
+	}
+}
+
+func TestOptimizeLimits(t *testing.T) {
+	in := encode(t, testGIF(5, 32))
+	for _, test := range []struct {
+		opts Options
+		err  error
+	}{
+		{Options{MaxBytes: int64(len(in) - 1)}, ErrTooLarge},
+		{Options{MaxFrames: 4}, ErrTooManyFrames},
+		{Options{MaxWidth: 31}, ErrDimensions},
+		{Options{MaxHeight: 31}, ErrDimensions},
+		{Options{MaxPixels: 4 * 32 * 32}, ErrDimensions},
+		{Options{MaxFrames: 5, MaxWidth: 32, MaxHeight: 32, MaxPixels: 5 * 32 * 32}, nil},
+	} {
+		var out bytes.Buffer
+		_, err := Optimize(bytes.NewReader(in), &out, test.opts)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%+v: got %v, want %v", test.opts, err, test.err)
+		}
+		if err != nil && out.Len() != 0 {
+			t.Errorf("%+v: wrote %d bytes", test.opts, out.Len())
+		}
+	}
+
+	_, err := Optimize(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n0000000")), &bytes.Buffer{}, Options{})
+	if !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,471 @@
+package gifopt
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"sort"
+)
+
+// Quantizer selects the algorithm building a reduced palette.
+type Quantizer int
+
+const (
+	// MedianCut repeatedly splits the box of colors holding the
+	// most pixels over the widest range in two, at its median.
+	// It is the default.
+	MedianCut Quantizer = iota
+	// Octree merges the least used leaves of an octree of the
+	// colors. It is faster but slightly less accurate.
+	Octree
+)
+
+// Dither selects how pixels are mapped to a reduced palette.
+type Dither int
+
+const (
+	// NoDither maps every pixel to the nearest color.
+	NoDither Dither = iota
+	// FloydSteinberg diffuses the error of every pixel to its
+	// neighbours. It gives the best looking results, but the
+	// noise it adds compresses poorly.
+	FloydSteinberg
+	// Ordered adds a regular Bayer pattern to the pixels, which
+	// compresses better than FloydSteinberg and does not flicker
+	// between frames.
+	Ordered
+)
+
+// QuantizeOptions configures Quantize.
+type QuantizeOptions struct {
+	// Colors is the largest number of colors of a palette,
+	// including the transparent color when a frame needs one.
+	// It is clamped to [2, 256]; zero means 256.
+	Colors    int
+	Quantizer Quantizer
+	Dither    Dither
+	// Global builds a single palette shared by all the frames,
+	// which saves a color table per frame. It always includes
+	// a transparent color.
+	Global bool
+}
+
+// Quantize reduces the palettes of the frames of g to at most
+// opts.Colors colors. Transparent pixels stay transparent.
+func Quantize(g *gif.GIF, opts QuantizeOptions) *gif.GIF {
+	n := opts.Colors
+	if n <= 0 || n > 256 {
+		n = 256
+	}
+	n = max(n, 2)
+
+	if opts.Global {
+		var colors []colorCount
+		for _, img := range g.Image {
+			colors = appendColors(colors, img)
+		}
+		palette := append(opts.Quantizer.palette(mergeColors(colors), n-1), color.Transparent)
+		for i, img := range g.Image {
+			g.Image[i] = remap(img, palette, opts.Dither)
+		}
+		g.Config.ColorModel = palette
+		return g
+	}
+
+	for i, img := range g.Image {
+		k := n
+		transparent := hasTransparent(img)
+		if transparent {
+			k--
+		}
+		palette := opts.Quantizer.palette(mergeColors(appendColors(nil, img)), k)
+		if transparent {
+			palette = append(palette, color.Transparent)
+		}
+		g.Image[i] = remap(img, palette, opts.Dither)
+	}
+	// the frames no longer use the global palette
+	g.Config.ColorModel = nil
+	return g
+}
+
+// colorCount is an opaque color with the number of pixels using it.
+type colorCount struct {
+	c [3]uint8
+	n int
+}
+
+// appendColors appends the opaque colors of img to colors.
+func appendColors(colors []colorCount, img *image.Paletted) []colorCount {
+	var counts [256]int
+	b := img.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for _, index := range img.Pix[img.PixOffset(b.Min.X, y):][:b.Dx()] {
+			counts[index]++
+		}
+	}
+	for index, n := range counts {
+		if n == 0 || index >= len(img.Palette) {
+			continue
+		}
+		if c, ok := opaque(img.Palette[index]); ok {
+			colors = append(colors, colorCount{c: c, n: n})
+		}
+	}
+	return colors
+}
+
+// mergeColors sums the counts of the same colors.
+func mergeColors(colors []colorCount) []colorCount {
+	sort.Slice(colors, func(i, j int) bool {
+		return pack(colors[i].c) < pack(colors[j].c)
+	})
+	merged := colors[:0]
+	for _, cc := range colors {
+		if len(merged) > 0 && merged[len(merged)-1].c == cc.c {
+			merged[len(merged)-1].n += cc.n
+		} else {
+			merged = append(merged, cc)
+		}
+	}
+	return merged
+}
+
+// opaque returns the 8-bit RGB components of c, and false if c
+// is transparent.
+func opaque(c color.Color) ([3]uint8, bool) {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return [3]uint8{}, false
+	}
+	return [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}, true
+}
+
+func pack(c [3]uint8) uint32 {
+	return uint32(c[0])<<16 | uint32(c[1])<<8 | uint32(c[2])
+}
+
+// hasTransparent reports whether a pixel of img is transparent.
+func hasTransparent(img *image.Paletted) bool {
+	transparent := transparentIndexes(img.Palette)
+	b := img.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for _, index := range img.Pix[img.PixOffset(b.Min.X, y):][:b.Dx()] {
+			if transparent[index] || int(index) >= len(img.Palette) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// palette returns at most n colors representing colors.
+func (q Quantizer) palette(colors []colorCount, n int) color.Palette {
+	if len(colors) <= n {
+		palette := make(color.Palette, len(colors))
+		for i, cc := range colors {
+			palette[i] = color.RGBA{cc.c[0], cc.c[1], cc.c[2], 255}
+		}
+		return palette
+	}
+	if q == Octree {
+		return octreePalette(colors, n)
+	}
+	return medianCutPalette(colors, n)
+}
+
+// colorBox is a box of the RGB cube used by the median cut.
+type colorBox struct {
+	colors []colorCount
+	n      int
+}
+
+// widest returns the channel over which the colors of the box
+// spread the most, and that spread.
+func (b colorBox) widest() (channel, spread int) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, cc := range b.colors {
+			lo = min(lo, int(cc.c[ch]))
+			hi = max(hi, int(cc.c[ch]))
+		}
+		if hi-lo > spread {
+			channel, spread = ch, hi-lo
+		}
+	}
+	return
+}
+
+// average returns the mean color of the box, weighted by the
+// number of pixels.
+func (b colorBox) average() color.Color {
+	var sum [3]int
+	for _, cc := range b.colors {
+		for ch := range sum {
+			sum[ch] += int(cc.c[ch]) * cc.n
+		}
+	}
+	return color.RGBA{
+		uint8((sum[0] + b.n/2) / b.n),
+		uint8((sum[1] + b.n/2) / b.n),
+		uint8((sum[2] + b.n/2) / b.n),
+		255,
+	}
+}
+
+func medianCutPalette(colors []colorCount, n int) color.Palette {
+	total := 0
+	for _, cc := range colors {
+		total += cc.n
+	}
+	boxes := []colorBox{{colors: colors, n: total}}
+	for len(boxes) < n {
+		best, bestScore, bestChannel := -1, 0, 0
+		for i, b := range boxes {
+			if len(b.colors) < 2 {
+				continue
+			}
+			channel, spread := b.widest()
+			if score := spread * b.n; score > bestScore {
+				best, bestScore, bestChannel = i, score, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b := boxes[best]
+		sort.Slice(b.colors, func(i, j int) bool {
+			return b.colors[i].c[bestChannel] < b.colors[j].c[bestChannel]
+		})
+		// split at the weighted median, leaving a color on each side
+		split, count := 1, b.colors[0].n
+		for split < len(b.colors)-1 && count+b.colors[split].n <= b.n/2 {
+			count += b.colors[split].n
+			split++
+		}
+		boxes[best] = colorBox{colors: b.colors[:split], n: count}
+		boxes = append(boxes, colorBox{colors: b.colors[split:], n: b.n - count})
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		palette[i] = b.average()
+	}
+	return palette
+}
+
+// octreeNode is a node of the octree quantizer. Leaves hold the
+// sum of the colors they stand for.
+type octreeNode struct {
+	sum      [3]int
+	n        int
+	children [8]*octreeNode
+	leaf     bool
+}
+
+func octreePalette(colors []colorCount, n int) color.Palette {
+	const depth = 8
+	root := &octreeNode{}
+	// internal nodes by level, to reduce the deepest first
+	var levels [depth][]*octreeNode
+	levels[0] = append(levels[0], root)
+	leaves := 0
+	for _, cc := range colors {
+		node := root
+		for level := 0; level < depth; level++ {
+			shift := 7 - level
+			i := (cc.c[0]>>shift&1)<<2 | (cc.c[1]>>shift&1)<<1 | cc.c[2]>>shift&1
+			child := node.children[i]
+			if child == nil {
+				child = &octreeNode{leaf: level == depth-1}
+				node.children[i] = child
+				if child.leaf {
+					leaves++
+				} else {
+					levels[level+1] = append(levels[level+1], child)
+				}
+			}
+			node = child
+		}
+		for ch := range node.sum {
+			node.sum[ch] += int(cc.c[ch]) * cc.n
+		}
+		node.n += cc.n
+	}
+
+	for level := depth - 1; level >= 0 && leaves > n; level-- {
+		nodes := levels[level]
+		for _, node := range nodes {
+			for _, child := range node.children {
+				if child != nil {
+					node.n += child.n
+				}
+			}
+		}
+		// merge the least used nodes first
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].n < nodes[j].n })
+		for _, node := range nodes {
+			if leaves <= n {
+				break
+			}
+			merged := 0
+			for i, child := range node.children {
+				if child == nil {
+					continue
+				}
+				for ch := range node.sum {
+					node.sum[ch] += child.sum[ch]
+				}
+				node.children[i] = nil
+				merged++
+			}
+			node.leaf = true
+			leaves -= merged - 1
+		}
+	}
+
+	palette := make(color.Palette, 0, leaves)
+	var walk func(node *octreeNode)
+	walk = func(node *octreeNode) {
+		if node.leaf {
+			palette = append(palette, color.RGBA{
+				uint8((node.sum[0] + node.n/2) / node.n),
+				uint8((node.sum[1] + node.n/2) / node.n),
+				uint8((node.sum[2] + node.n/2) / node.n),
+				255,
+			})
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return palette
+}
+
+// bayer is the 4x4 Bayer matrix used by the ordered dithering.
+var bayer = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// remap draws img with the palette, whose last color is the
+// transparent one if img has transparent pixels.
+func remap(img *image.Paletted, palette color.Palette, dither Dither) *image.Paletted {
+	return remapFunc(img.Rect, func(x, y int) ([3]uint8, bool) {
+		index := img.Pix[img.PixOffset(x, y)]
+		if int(index) >= len(img.Palette) {
+			return [3]uint8{}, false
+		}
+		return opaque(img.Palette[index])
+	}, palette, dither)
+}
+
+// remapFunc draws the pixels within b, whose opaque colors are
+// given by at, with the palette.
+func remapFunc(b image.Rectangle, at func(x, y int) ([3]uint8, bool), palette color.Palette, dither Dither) *image.Paletted {
+	out := image.NewPaletted(b, palette)
+	m := newMatcher(palette)
+	transInd, _ := firstTransparent(transparentIndexes(palette))
+
+	width := b.Dx()
+	var cur, next [][3]float64
+	if dither == FloydSteinberg {
+		cur = make([][3]float64, width+2)
+		next = make([][3]float64, width+2)
+	}
+	spread := 255 / math.Cbrt(float64(len(m.colors)))
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		dst := out.Pix[out.PixOffset(b.Min.X, y):][:width]
+		for x := range dst {
+			c, ok := at(b.Min.X+x, y)
+			if !ok {
+				dst[x] = transInd
+				continue
+			}
+			switch dither {
+			case NoDither:
+				dst[x] = m.indexes[m.nearest(c)]
+			case Ordered:
+				t := ((bayer[y&3][(b.Min.X+x)&3]+0.5)/16 - 0.5) * spread
+				dst[x] = m.indexes[m.nearest(clampColor([3]float64{
+					float64(c[0]) + t, float64(c[1]) + t, float64(c[2]) + t,
+				}))]
+			case FloydSteinberg:
+				want := [3]float64{}
+				for ch := range want {
+					want[ch] = float64(c[ch]) + cur[x+1][ch]
+				}
+				i := m.nearest(clampColor(want))
+				dst[x] = m.indexes[i]
+				got := m.colors[i]
+				for ch := range want {
+					e := want[ch] - float64(got[ch])
+					cur[x+2][ch] += e * 7 / 16
+					next[x][ch] += e * 3 / 16
+					next[x+1][ch] += e * 5 / 16
+					next[x+2][ch] += e * 1 / 16
+				}
+			}
+		}
+		if dither == FloydSteinberg {
+			cur, next = next, cur
+			clear(next)
+		}
+	}
+	return out
+}
+
+func clampColor(c [3]float64) (out [3]uint8) {
+	for ch, v := range c {
+		out[ch] = uint8(math.Round(max(0, min(255, v))))
+	}
+	return
+}
+
+// matcher finds the nearest opaque color of a palette.
+type matcher struct {
+	colors  [][3]uint8
+	indexes []uint8 // palette index of colors[i]
+	cache   map[uint32]int
+}
+
+func newMatcher(palette color.Palette) *matcher {
+	m := &matcher{cache: make(map[uint32]int)}
+	for i, c := range palette {
+		if rgb, ok := opaque(c); ok {
+			m.colors = append(m.colors, rgb)
+			m.indexes = append(m.indexes, uint8(i))
+		}
+	}
+	return m
+}
+
+// nearest returns the position in m.colors of the color nearest
+// to c.
+func (m *matcher) nearest(c [3]uint8) int {
+	key := pack(c)
+	if i, ok := m.cache[key]; ok {
+		return i
+	}
+	best, bestDist := 0, math.MaxInt
+	for i, p := range m.colors {
+		d := 0
+		for ch := range p {
+			diff := int(p[ch]) - int(c[ch])
+			d += diff * diff
+		}
+		if d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	m.cache[key] = best
+	return best
+}
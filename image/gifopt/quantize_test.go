@@ -0,0 +1,127 @@
+package gifopt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+// gradientGIF returns frames of a colorful gradient with a
+// transparent hole in the middle.
+func gradientGIF(frames int) *gif.GIF {
+	g := &gif.GIF{Config: image.Config{Width: 64, Height: 64}}
+	for i := 0; i < frames; i++ {
+		palette := color.Palette{color.Transparent}
+		for p := 1; p < 256; p++ {
+			palette = append(palette, color.RGBA{uint8(p), uint8(255 - p), uint8(p/2 + i*20), 255})
+		}
+		img := image.NewPaletted(image.Rect(0, 0, 64, 64), palette)
+		for p := range img.Pix {
+			img.Pix[p] = uint8(1 + (p%64*4+p/64+i)%255)
+		}
+		draw.Draw(img, image.Rect(28, 28, 36, 36), image.Transparent, image.Point{}, draw.Src)
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+	}
+	return g
+}
+
+// meanError returns the mean squared RGB error between the opaque
+// pixels of two frames, and fails if their transparency differs.
+func meanError(t *testing.T, got, want *image.Paletted) float64 {
+	t.Helper()
+	sum, n := 0.0, 0
+	b := want.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gc, gok := opaque(got.At(x, y))
+			wc, wok := opaque(want.At(x, y))
+			if gok != wok {
+				t.Fatalf("pixel (%d, %d): transparency changed", x, y)
+			}
+			for ch := range gc {
+				d := float64(gc[ch]) - float64(wc[ch])
+				sum += d * d
+			}
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestQuantize(t *testing.T) {
+	for _, q := range []Quantizer{MedianCut, Octree} {
+		for _, d := range []Dither{NoDither, FloydSteinberg, Ordered} {
+			t.Run(fmt.Sprint(q, d), func(t *testing.T) {
+				orig := gradientGIF(2)
+				g := Quantize(gradientGIF(2), QuantizeOptions{Colors: 16, Quantizer: q, Dither: d})
+				for i, img := range g.Image {
+					if len(img.Palette) > 16 {
+						t.Fatalf("frame %d: %d colors", i, len(img.Palette))
+					}
+					if mse := meanError(t, img, orig.Image[i]); mse > 400 {
+						t.Errorf("frame %d: mean squared error %v", i, mse)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestQuantizeExact(t *testing.T) {
+	g := testGIF(2, 16)
+	orig := testGIF(2, 16)
+	Quantize(g, QuantizeOptions{Colors: 4})
+	for i := range g.Image {
+		if mse := meanError(t, g.Image[i], orig.Image[i]); mse != 0 {
+			t.Errorf("frame %d changed: %v", i, mse)
+		}
+	}
+}
+
+func TestQuantizeGlobal(t *testing.T) {
+	g := Quantize(gradientGIF(3), QuantizeOptions{Colors: 32, Global: true})
+	global, ok := g.Config.ColorModel.(color.Palette)
+	if !ok || len(global) > 32 {
+		t.Fatalf("global palette %v", g.Config.ColorModel)
+	}
+	for i, img := range g.Image {
+		if len(img.Palette) != len(global) || &img.Palette[0] != &global[0] {
+			t.Errorf("frame %d does not use the global palette", i)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gif.DecodeAll(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptimizeTargetSize(t *testing.T) {
+	in := encode(t, gradientGIF(6))
+	target := int64(len(in) / 4)
+	var out bytes.Buffer
+	res, err := Optimize(bytes.NewReader(in), &out, Options{TargetSize: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.BytesOut > target || int64(out.Len()) != res.BytesOut || res.Colors == 0 {
+		t.Fatalf("%+v, target %d", res, target)
+	}
+	if _, err := gif.DecodeAll(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	_, err = Optimize(bytes.NewReader(in), &out, Options{TargetSize: 100})
+	if !errors.Is(err, ErrTargetSize) || out.Len() != 0 {
+		t.Fatalf("got %v and %d bytes", err, out.Len())
+	}
+}
@@ -0,0 +1,224 @@
+package gifopt
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+
+	ximage "github.com/XiBao/goutil/image"
+)
+
+// Filter selects how Resize resamples the frames.
+type Filter int
+
+const (
+	// Nearest takes the nearest pixel. It is the fastest and
+	// keeps hard edges, but aliases when downscaling.
+	Nearest Filter = iota
+	// Bilinear interpolates linearly between the pixels.
+	Bilinear
+	// Lanczos uses a Lanczos kernel with 3 lobes. It is the
+	// sharpest, at the cost of some ringing around edges.
+	Lanczos
+)
+
+// Resize returns g scaled to a canvas of width by height pixels.
+// Every frame is composited over the previous ones as displayed,
+// honouring their disposal methods, and the whole canvas is
+// resampled with filter, then quantized to its own palette of at
+// most 256 colors. The delays and loop count of g are kept.
+//
+// Pixels of the resampled frames that are more than half
+// transparent become transparent. The frames of the result cover
+// the whole canvas; the ones following a frame with transparent
+// pixels dispose it to the background. Run InterframeCompress and
+// CropFrames on the result to make it small again. A width or
+// height below 1 is taken as 1.
+func Resize(g *gif.GIF, width, height int, filter Filter) *gif.GIF {
+	r := image.Rect(0, 0, max(1, width), max(1, height))
+	return resize(g, r, r, filter)
+}
+
+// Thumbnail returns g scaled to fit a square canvas of size by
+// size pixels, keeping its aspect ratio. It is centered on the
+// canvas, the rest of which is transparent. A size below 1 is
+// taken as 1. See Resize.
+func Thumbnail(g *gif.GIF, size int, filter Filter) *gif.GIF {
+	size = max(1, size)
+	b := ximage.CanvasBounds(g)
+	width, height := size, size
+	switch {
+	case b.Empty():
+	case b.Dx() > b.Dy():
+		height = max(1, int(math.Round(float64(size*b.Dy())/float64(b.Dx()))))
+	default:
+		width = max(1, int(math.Round(float64(size*b.Dx())/float64(b.Dy()))))
+	}
+	x, y := (size-width)/2, (size-height)/2
+	return resize(g, image.Rect(0, 0, size, size), image.Rect(x, y, x+width, y+height), filter)
+}
+
+// resize scales the canvas of g to the dst rectangle of a canvas
+// of the given bounds.
+func resize(g *gif.GIF, canvas, dst image.Rectangle, filter Filter) *gif.GIF {
+	out := &gif.GIF{
+		LoopCount: g.LoopCount,
+		Config:    image.Config{Width: canvas.Dx(), Height: canvas.Dy()},
+	}
+	src := ximage.CanvasBounds(g)
+	if src.Empty() || dst.Empty() {
+		return out
+	}
+	xw := resampleWeights(dst.Dx(), src.Dx(), filter)
+	yw := resampleWeights(dst.Dy(), src.Dy(), filter)
+
+	for i, visible := range ximage.NewCompositor(g).Frames() {
+		frame, transparent := quantizeRGBA(resample(visible, dst, xw, yw))
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		if i > 0 && transparent {
+			// let the canvas show through the transparent pixels
+			out.Disposal[i-1] = gif.DisposalBackground
+		}
+		out.Image = append(out.Image, frame)
+		out.Delay = append(out.Delay, delay)
+		out.Disposal = append(out.Disposal, gif.DisposalNone)
+	}
+	return out
+}
+
+// weight is the contribution of a source pixel to a resampled one.
+type weight struct {
+	index int
+	w     float64
+}
+
+// resampleWeights returns, for every of the n pixels of a row or
+// column resampled from srcN pixels, the weights of the source
+// pixels it is made of.
+func resampleWeights(n, srcN int, filter Filter) [][]weight {
+	scale := float64(srcN) / float64(n)
+	weights := make([][]weight, n)
+	if filter == Nearest {
+		for i := range weights {
+			j := min(int((float64(i)+0.5)*scale), srcN-1)
+			weights[i] = []weight{{index: j, w: 1}}
+		}
+		return weights
+	}
+
+	support, kernel := 1.0, func(x float64) float64 {
+		return max(0, 1-math.Abs(x))
+	}
+	if filter == Lanczos {
+		support, kernel = 3, func(x float64) float64 {
+			if x == 0 {
+				return 1
+			}
+			if math.Abs(x) >= 3 {
+				return 0
+			}
+			x *= math.Pi
+			return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+		}
+	}
+	// widen the kernel when downscaling, not to alias
+	filterScale := max(scale, 1)
+	radius := support * filterScale
+	for i := range weights {
+		center := (float64(i) + 0.5) * scale
+		lo := max(int(math.Floor(center-radius)), 0)
+		hi := min(int(math.Ceil(center+radius)), srcN)
+		sum := 0.0
+		for j := lo; j < hi; j++ {
+			if w := kernel((float64(j) + 0.5 - center) / filterScale); w != 0 {
+				weights[i] = append(weights[i], weight{index: j, w: w})
+				sum += w
+			}
+		}
+		for k := range weights[i] {
+			weights[i][k].w /= sum
+		}
+	}
+	return weights
+}
+
+// resample scales src to the dst rectangle, first along the rows,
+// then along the columns.
+func resample(src *image.RGBA, dst image.Rectangle, xw, yw [][]weight) *image.RGBA {
+	b := src.Rect
+	width := dst.Dx()
+	rows := make([]float64, b.Dy()*width*4)
+	for y := 0; y < b.Dy(); y++ {
+		in := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x, ws := range xw {
+			p := rows[(y*width+x)*4:][:4]
+			for _, w := range ws {
+				for ch := range p {
+					p[ch] += w.w * float64(in[w.index*4+ch])
+				}
+			}
+		}
+	}
+
+	out := image.NewRGBA(dst)
+	for y, ws := range yw {
+		o := out.Pix[out.PixOffset(dst.Min.X, dst.Min.Y+y):]
+		for x := 0; x < width; x++ {
+			var p [4]float64
+			for _, w := range ws {
+				for ch := range p {
+					p[ch] += w.w * rows[(w.index*width+x)*4+ch]
+				}
+			}
+			// colors are premultiplied, they can't exceed alpha
+			a := math.Round(max(0, min(255, p[3])))
+			for ch := 0; ch < 3; ch++ {
+				o[x*4+ch] = uint8(math.Round(max(0, min(a, p[ch]))))
+			}
+			o[x*4+3] = uint8(a)
+		}
+	}
+	return out
+}
+
+// quantizeRGBA returns m with a palette of at most 256 colors,
+// and whether it has transparent pixels.
+func quantizeRGBA(m *image.RGBA) (*image.Paletted, bool) {
+	at := func(x, y int) ([3]uint8, bool) {
+		c := m.RGBAAt(x, y)
+		if c.A < 128 {
+			return [3]uint8{}, false
+		}
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		return [3]uint8{n.R, n.G, n.B}, true
+	}
+	counts := make(map[[3]uint8]int)
+	transparent := false
+	b := m.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c, ok := at(x, y); ok {
+				counts[c]++
+			} else {
+				transparent = true
+			}
+		}
+	}
+	colors := make([]colorCount, 0, len(counts))
+	for c, n := range counts {
+		colors = append(colors, colorCount{c: c, n: n})
+	}
+	n := 256
+	if transparent {
+		n--
+	}
+	palette := MedianCut.palette(mergeColors(colors), n)
+	if transparent {
+		palette = append(palette, color.Transparent)
+	}
+	return remapFunc(b, at, palette, NoDither), transparent
+}
@@ -0,0 +1,125 @@
+package gifopt
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+// squaresGIF returns a 40x20 animation of a red square moving over
+// a white background, drawn with a mix of disposal methods.
+func squaresGIF() *gif.GIF {
+	palette := color.Palette{color.White, color.RGBA{255, 0, 0, 255}, color.Transparent}
+	g := &gif.GIF{Config: image.Config{Width: 40, Height: 20}, LoopCount: 3}
+	bg := image.NewPaletted(image.Rect(0, 0, 40, 20), palette)
+	g.Image = append(g.Image, bg)
+	for i := 0; i < 4; i++ {
+		img := image.NewPaletted(image.Rect(i*8, 4, i*8+12, 16), palette)
+		draw.Draw(img, img.Rect, image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(i*8+2, 6, i*8+10, 14), image.NewUniform(palette[1]), image.Point{}, draw.Src)
+		g.Image = append(g.Image, img)
+	}
+	g.Delay = []int{10, 20, 30, 40, 50}
+	g.Disposal = []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalPrevious, gif.DisposalBackground, gif.DisposalNone}
+	return g
+}
+
+func TestResize(t *testing.T) {
+	for _, filter := range []Filter{Nearest, Bilinear, Lanczos} {
+		t.Run(fmt.Sprint(filter), func(t *testing.T) {
+			g := squaresGIF()
+			want := render(g)
+			r := Resize(g, 20, 10, filter)
+			if r.Config.Width != 20 || r.Config.Height != 10 || r.LoopCount != 3 {
+				t.Fatalf("config %+v, loop count %d", r.Config, r.LoopCount)
+			}
+			if fmt.Sprint(r.Delay) != fmt.Sprint(g.Delay) {
+				t.Fatalf("delays %v", r.Delay)
+			}
+			got := render(r)
+			for i := range want {
+				// a corner of the background, and a pixel the
+				// fourth frame disposes to the background
+				for _, p := range []image.Point{{1, 1}, {10, 4}} {
+					w := want[i].RGBAAt(p.X*2, p.Y*2)
+					if c := got[i].RGBAAt(p.X, p.Y); c != w {
+						t.Errorf("frame %d, pixel %v: %v, want %v", i, p, c, w)
+					}
+				}
+				if i > 0 {
+					center := image.Pt((i-1)*4+3, 5)
+					if c := got[i].RGBAAt(center.X, center.Y); c != (color.RGBA{255, 0, 0, 255}) {
+						t.Errorf("frame %d: square missing, %v", i, c)
+					}
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := gif.EncodeAll(&buf, r); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := gif.DecodeAll(&buf); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	r := Thumbnail(squaresGIF(), 16, Bilinear)
+	if r.Config.Width != 16 || r.Config.Height != 16 {
+		t.Fatalf("config %+v", r.Config)
+	}
+	for i, img := range r.Image {
+		if img.Rect != image.Rect(0, 4, 16, 12) {
+			t.Errorf("frame %d: bounds %v", i, img.Rect)
+		}
+	}
+	frames := render(r)
+	if c := frames[0].RGBAAt(8, 1); c.A != 0 {
+		t.Errorf("padding not transparent: %v", c)
+	}
+	if c := frames[0].RGBAAt(8, 8); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("background %v", c)
+	}
+}
+
+func TestResizeEmpty(t *testing.T) {
+	for _, g := range []*gif.GIF{
+		Resize(squaresGIF(), 0, -3, Nearest),
+		Thumbnail(squaresGIF(), 0, Lanczos),
+	} {
+		if g.Config.Width != 1 || g.Config.Height != 1 || len(g.Image) != 5 {
+			t.Errorf("config %+v, %d frames", g.Config, len(g.Image))
+		}
+		encode(t, g)
+	}
+}
+
+func TestOptimizeResize(t *testing.T) {
+	in := encode(t, squaresGIF())
+	var out bytes.Buffer
+	res, err := Optimize(bytes.NewReader(in), &out, Options{Width: 80, Threshold: 3, Crop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Optimized {
+		t.Fatalf("%+v", res)
+	}
+	g, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Config.Width != 80 || g.Config.Height != 40 || len(g.Image) != 5 {
+		t.Fatalf("%+v, %d frames", g.Config, len(g.Image))
+	}
+
+	_, err = Optimize(bytes.NewReader(in), &out, Options{Width: 80, MaxWidth: 64})
+	if err != ErrDimensions {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package gifopt
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"math"
+	"time"
+
+	ximage "github.com/XiBao/goutil/image"
+)
+
+const (
+	// MinDelay is the shortest delay, in hundredths of a second,
+	// the functions of this file set. Browsers play the frames of
+	// shorter delays at 10 frames per second.
+	MinDelay = 2
+	// MaxFPS is the highest frame rate the delays can encode
+	// without being slowed down by browsers.
+	MaxFPS = 100 / MinDelay
+)
+
+// Duration returns how long g plays once.
+func Duration(g *gif.GIF) time.Duration {
+	total := 0
+	for i := range g.Image {
+		total += delay(g, i)
+	}
+	return time.Duration(total) * 10 * time.Millisecond
+}
+
+// SetFPS sets the delays of all the frames of g so that it plays
+// fps frames per second, which changes its duration. The frame
+// rate is limited to MaxFPS.
+func SetFPS(g *gif.GIF, fps float64) *gif.GIF {
+	if fps <= 0 {
+		return g
+	}
+	step := 100 / min(fps, MaxFPS)
+	g.Delay = g.Delay[:0]
+	for i := range g.Image {
+		// round the times the frames start at, not to drift
+		g.Delay = append(g.Delay, int(math.Round(float64(i+1)*step)-math.Round(float64(i)*step)))
+	}
+	return g
+}
+
+// ScaleDuration multiplies the delays of the frames of g by
+// factor: 2 plays g twice as slow and 0.5 twice as fast. The
+// delays that are not zero are kept at least MinDelay, so a GIF
+// sped up a lot plays slower than asked.
+func ScaleDuration(g *gif.GIF, factor float64) *gif.GIF {
+	if factor <= 0 {
+		return g
+	}
+	start, delays := 0, make([]int, len(g.Image))
+	for i := range g.Image {
+		d := delay(g, i)
+		delays[i] = int(math.Round(float64(start+d)*factor) - math.Round(float64(start)*factor))
+		if d > 0 {
+			delays[i] = max(delays[i], MinDelay)
+		}
+		start += d
+	}
+	g.Delay = delays
+	return g
+}
+
+// SetLoopCount sets how many times g plays, as gif.GIF.LoopCount
+// does: 0 loops forever, -1 plays g once and n plays it n+1 times.
+func SetLoopCount(g *gif.GIF, count int) *gif.GIF {
+	g.LoopCount = count
+	return g
+}
+
+// MergeIdentical merges the consecutive frames of g that display
+// the same canvas into the first one, whose delay becomes the sum
+// of theirs. GIFs converted from videos often repeat frames.
+func MergeIdentical(g *gif.GIF) *gif.GIF {
+	var (
+		kept []keptFrame
+		last []byte
+	)
+	for i, canvas := range ximage.NewCompositor(g).Frames() {
+		if len(kept) > 0 && bytes.Equal(canvas.Pix, last) {
+			kept[len(kept)-1].delay += delay(g, i)
+			continue
+		}
+		kept = append(kept, keptFrame{index: i, delay: delay(g, i)})
+		last = append(last[:0], canvas.Pix...)
+	}
+	if len(kept) == len(g.Image) {
+		return g
+	}
+	return keepFrames(g, kept)
+}
+
+// DropFrames drops frames of g so that it plays at most fps
+// frames per second, keeping its duration: the frames start at
+// multiples of 1/fps, and the frames displayed in between are
+// dropped. Frames without delay are dropped as well. The frame
+// rate is limited to MaxFPS.
+func DropFrames(g *gif.GIF, fps float64) *gif.GIF {
+	total := 0
+	for i := range g.Image {
+		total += delay(g, i)
+	}
+	if fps <= 0 || total == 0 {
+		return g
+	}
+	step := 100 / min(fps, MaxFPS)
+
+	var kept []keptFrame
+	i, end := 0, delay(g, 0) // the frame displayed and when it ends
+	for n := 0; float64(n)*step < float64(total); n++ {
+		at := float64(n) * step
+		for float64(end) <= at {
+			i++
+			end += delay(g, i)
+		}
+		if len(kept) > 0 && kept[len(kept)-1].index == i {
+			continue
+		}
+		// the delays are set below, from the start of the next frame
+		kept = append(kept, keptFrame{index: i, delay: int(math.Round(at))})
+	}
+	for k := range kept {
+		next := total
+		if k+1 < len(kept) {
+			next = kept[k+1].delay
+		}
+		kept[k].delay = next - kept[k].delay
+	}
+	if len(kept) == len(g.Image) {
+		g.Delay = make([]int, len(kept))
+		for k, f := range kept {
+			g.Delay[k] = f.delay
+		}
+		return g
+	}
+	return keepFrames(g, kept)
+}
+
+// TrimTime returns the part of g displayed from start to end. The
+// frames displayed only before start or after end are removed,
+// and the delays of the first and last frames are shortened to
+// the range. An end of zero keeps g to its end. The times are
+// rounded to hundredths of a second. When the range is empty, or
+// g is shorter than start, the result is the single frame
+// displayed at start, or the last one, without delay.
+func TrimTime(g *gif.GIF, start, end time.Duration) *gif.GIF {
+	from, to := hundredths(start), hundredths(end)
+	var kept []keptFrame
+	t := 0    // when the frame starts
+	last := 0 // the last frame started by start
+	for i := range g.Image {
+		d := delay(g, i)
+		if t <= from {
+			last = i
+		}
+		if to > 0 && t >= to {
+			break
+		}
+		if t >= from || t+d > from {
+			finish := t + d
+			if to > 0 {
+				finish = min(finish, to)
+			}
+			kept = append(kept, keptFrame{index: i, delay: finish - max(t, from)})
+		}
+		t += d
+	}
+	if len(kept) == 0 && len(g.Image) > 0 {
+		kept = append(kept, keptFrame{index: last})
+	}
+	return keepFrames(g, kept)
+}
+
+// hundredths converts d to hundredths of a second.
+func hundredths(d time.Duration) int {
+	return int(d.Round(10*time.Millisecond) / (10 * time.Millisecond))
+}
+
+// delay returns the delay of the i-th frame of g.
+func delay(g *gif.GIF, i int) int {
+	if i < len(g.Delay) {
+		return g.Delay[i]
+	}
+	return 0
+}
+
+// keptFrame is a frame of a GIF kept by keepFrames, with its new
+// delay.
+type keptFrame struct {
+	index int
+	delay int
+}
+
+// keepFrames returns a GIF made of the kept frames of g, in
+// order, which display what they do in g. A kept frame drawn over
+// a canvas that differs from the one in g, once the frames in
+// between are removed, is replaced by a frame of the whole canvas
+// with the pixels that changed. When some must be cleared, the
+// previous frame is replaced by the whole canvas as well, and
+// disposed to the background. Those frames are quantized to at
+// most 256 colors; run InterframeCompress and CropFrames to make
+// them small again.
+func keepFrames(g *gif.GIF, kept []keptFrame) *gif.GIF {
+	b := ximage.CanvasBounds(g)
+	out := &gif.GIF{
+		LoopCount:       g.LoopCount,
+		BackgroundIndex: g.BackgroundIndex,
+		Config:          g.Config,
+	}
+	out.Config.Width, out.Config.Height = b.Dx(), b.Dy()
+
+	src, dst := ximage.NewCompositor(g), ximage.NewCompositor(out)
+	last := image.NewRGBA(b) // the canvas displayed by the last frame of out
+	for _, k := range kept {
+		for src.Index() < k.index {
+			src.Next()
+		}
+		same := bytes.Equal(src.Before().Pix, dst.Before().Pix)
+		target, _ := src.Next()
+
+		img, disposal := g.Image[k.index], byte(gif.DisposalNone)
+		if k.index < len(g.Disposal) {
+			disposal = g.Disposal[k.index]
+		}
+		if !same {
+			var ok bool
+			if img, ok = changes(dst.Before(), target); !ok {
+				j := len(out.Image) - 1
+				out.Image[j], _ = quantizeRGBA(last)
+				out.Disposal[j] = gif.DisposalBackground
+				// replay the frames to clear the canvas
+				dst = ximage.NewCompositor(out)
+				for range dst.Frames() {
+				}
+				img, _ = changes(dst.Before(), target)
+			}
+			disposal = gif.DisposalNone
+		}
+		out.Image = append(out.Image, img)
+		out.Delay = append(out.Delay, k.delay)
+		out.Disposal = append(out.Disposal, disposal)
+		dst.Next()
+		copy(last.Pix, target.Pix)
+	}
+	return out
+}
+
+// changes returns a frame of the whole canvas, which drawn over
+// before displays target, or false if some pixels of before would
+// need to be cleared.
+func changes(before, target *image.RGBA) (*image.Paletted, bool) {
+	diff := image.NewRGBA(target.Rect)
+	for i := 0; i < len(target.Pix); i += 4 {
+		t, b := target.Pix[i:i+4], before.Pix[i:i+4]
+		switch {
+		case bytes.Equal(t, b):
+			// left transparent
+		case t[3] >= 128:
+			copy(diff.Pix[i:i+4], t)
+		case b[3] != 0:
+			return nil, false
+		}
+	}
+	img, _ := quantizeRGBA(diff)
+	return img, true
+}
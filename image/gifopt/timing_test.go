@@ -0,0 +1,116 @@
+package gifopt
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSetFPS(t *testing.T) {
+	g := SetFPS(squaresGIF(), 30)
+	if want := []int{3, 4, 3, 3, 4}; !slices.Equal(g.Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+	if d := Duration(g); d != 170*time.Millisecond {
+		t.Errorf("duration %v, want 170ms", d)
+	}
+	if g = SetFPS(squaresGIF(), 1000); !slices.Equal(g.Delay, []int{2, 2, 2, 2, 2}) {
+		t.Errorf("delays %v above MaxFPS", g.Delay)
+	}
+}
+
+func TestScaleDuration(t *testing.T) {
+	g := &gif.GIF{Image: make([]*image.Paletted, 4), Delay: []int{10, 5, 0, 1}}
+	if want := []int{5, 3, 0, 2}; !slices.Equal(ScaleDuration(g, 0.5).Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+	g = ScaleDuration(squaresGIF(), 2)
+	if want := []int{20, 40, 60, 80, 100}; !slices.Equal(g.Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+}
+
+func TestMergeIdentical(t *testing.T) {
+	g := squaresGIF()
+	want := render(g)
+	// a frame that changes nothing, and the same square again
+	blank := image.NewPaletted(image.Rect(0, 0, 1, 1), g.Image[1].Palette)
+	blank.Pix[0] = 2
+	g.Image = slices.Insert(g.Image, 1, blank)
+	g.Image = slices.Insert(g.Image, 3, g.Image[2])
+	g.Delay = []int{10, 5, 20, 7, 30, 40, 50}
+	g.Disposal = slices.Insert(g.Disposal, 1, gif.DisposalNone)
+	g.Disposal = slices.Insert(g.Disposal, 3, gif.DisposalPrevious)
+
+	g = MergeIdentical(g)
+	if want := []int{15, 27, 30, 40, 50}; !slices.Equal(g.Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+	sameFrames(t, render(g), want)
+}
+
+func TestDropFrames(t *testing.T) {
+	g := squaresGIF()
+	frames := render(g)
+	// the frame cleared by the dropped one must be cleared anyway
+	g = DropFrames(g, 2)
+	if want := []int{50, 50, 50}; !slices.Equal(g.Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+	if g.Disposal[1] != gif.DisposalBackground {
+		t.Errorf("frame 1 disposed with %d, want the background", g.Disposal[1])
+	}
+	if g.LoopCount != 3 || g.Config.Width != 40 || g.Config.Height != 20 {
+		t.Errorf("loop count %d and canvas %+v not kept", g.LoopCount, g.Config)
+	}
+	sameFrames(t, render(g), []*image.RGBA{frames[0], frames[2], frames[4]})
+
+	g = DropFrames(squaresGIF(), 100)
+	if want := []int{10, 20, 30, 40, 50}; !slices.Equal(g.Delay, want) {
+		t.Errorf("delays %v, want %v", g.Delay, want)
+	}
+}
+
+func TestTrimTime(t *testing.T) {
+	frames := render(squaresGIF())
+	for _, tt := range []struct {
+		start, end time.Duration
+		delays     []int
+		frames     []*image.RGBA
+	}{
+		{0, 0, []int{10, 20, 30, 40, 50}, frames},
+		{400 * time.Millisecond, 0, []int{20, 40, 50}, frames[2:]},
+		{150 * time.Millisecond, 700 * time.Millisecond, []int{15, 30, 10}, frames[1:4]},
+		{500 * time.Millisecond, 500 * time.Millisecond, []int{0}, frames[2:3]},
+		{2 * time.Second, 0, []int{0}, frames[4:]},
+	} {
+		g := TrimTime(squaresGIF(), tt.start, tt.end)
+		if !slices.Equal(g.Delay, tt.delays) {
+			t.Errorf("%v-%v: delays %v, want %v", tt.start, tt.end, g.Delay, tt.delays)
+		}
+		sameFrames(t, render(g), tt.frames)
+		encode(t, g)
+	}
+}
+
+func TestOptimizeTiming(t *testing.T) {
+	g := testGIF(4, 32)
+	g.Image = append(g.Image, g.Image[3], g.Image[3])
+	g.Delay = append(g.Delay, 10, 10)
+	g.Disposal = append(g.Disposal, gif.DisposalNone, gif.DisposalNone)
+	var out bytes.Buffer
+	_, err := Optimize(bytes.NewReader(encode(t, g)), &out, Options{Threshold: 10, Merge: true, Speed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{5, 5, 5, 15}; !slices.Equal(d.Delay, want) {
+		t.Errorf("delays %v, want %v", d.Delay, want)
+	}
+}
@@ -0,0 +1,420 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+)
+
+// maxExifLen is the most Probe reads of EXIF data. The
+// orientation is at its start.
+const maxExifLen = 64 << 10
+
+// ErrInvalidHeader is returned when the headers of an image are
+// malformed.
+var ErrInvalidHeader = errors.New("image: invalid header")
+
+// Info describes an image, as probed by Probe.
+type Info struct {
+	// Sniffed is the type of the image.
+	Sniffed
+	// Width and Height are the size of the image, or of the
+	// canvas of an animation, as stored: see DisplaySize.
+	Width  int
+	Height int
+	// Frames is the number of frames, 1 for a still image.
+	Frames int
+	// ColorModel is the color model the image decodes to.
+	ColorModel color.Model
+	// Orientation is the EXIF orientation, from 1 to 8, and 1
+	// when the image has none.
+	Orientation int
+}
+
+// DisplaySize returns the size of the image once rotated as its
+// orientation tells.
+func (i Info) DisplaySize() (width, height int) {
+	if i.Orientation >= 5 && i.Orientation <= 8 {
+		return i.Height, i.Width
+	}
+	return i.Width, i.Height
+}
+
+// Probe reads the headers of the JPEG, PNG, GIF or WebP image
+// from r and describes it, without decoding the pixels. The type
+// is sniffed as Sniff does: Probe returns ErrUnknownType or
+// ErrUnsupportedType for other content. Counting the frames of
+// a GIF, or finding the EXIF data of an extended WebP, reads it
+// to its end.
+func Probe(r io.Reader) (Info, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return Info{}, err
+	}
+	info := Info{Sniffed: SniffBytes(head), Frames: 1, Orientation: 1}
+	switch info.extensionOr("") {
+	case "jpg":
+		err = probeJPEG(br, &info)
+	case "png":
+		err = probePNG(br, &info)
+	case "gif":
+		err = probeGIF(br, &info)
+	case "webp":
+		err = probeWebP(br, &info)
+	default:
+		if !info.Known() {
+			return info, ErrUnknownType
+		}
+		return info, fmt.Errorf("%w: %s", ErrUnsupportedType, info.MIME)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %v", ErrInvalidHeader, io.ErrUnexpectedEOF)
+	}
+	return info, err
+}
+
+// decodeConfig fills in the size and color model of the image
+// with image.DecodeConfig, and returns a reader of the image from
+// its start again.
+func decodeConfig(r io.Reader, info *Info) (*bufio.Reader, error) {
+	var read bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &read))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	info.Width, info.Height, info.ColorModel = config.Width, config.Height, config.ColorModel
+	return bufio.NewReader(io.MultiReader(&read, r)), nil
+}
+
+// probeJPEG reads the orientation from the EXIF segment of a JPEG.
+func probeJPEG(r io.Reader, info *Info) error {
+	br, err := decodeConfig(r, info)
+	if err != nil {
+		return err
+	}
+	if _, err := br.Discard(2); err != nil { // SOI
+		return err
+	}
+	for {
+		marker, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if marker != 0xff {
+			return fmt.Errorf("%w: missing JPEG marker", ErrInvalidHeader)
+		}
+		if marker, err = br.ReadByte(); err != nil {
+			return err
+		}
+		switch {
+		case marker == 0xff: // fill byte
+			br.UnreadByte()
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd8): // without length
+			continue
+		case marker == 0xd9 || marker == 0xda: // EOI, SOS: the headers are over
+			return nil
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return fmt.Errorf("%w: invalid JPEG segment length", ErrInvalidHeader)
+		}
+		if marker != 0xe1 { // APP1
+			if _, err := br.Discard(n); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := readChunk(br, n)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			info.Orientation = exifOrientation(data)
+		}
+	}
+}
+
+// probePNG reads the number of frames of an APNG and the
+// orientation from the chunks of a PNG preceding its pixels.
+func probePNG(r io.Reader, info *Info) error {
+	br, err := decodeConfig(r, info)
+	if err != nil {
+		return err
+	}
+	if _, err := br.Discard(8); err != nil { // signature
+		return err
+	}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return err
+		}
+		n, err := chunkLen(binary.BigEndian.Uint32(header[:4]))
+		if err != nil {
+			return err
+		}
+		switch string(header[4:]) {
+		case "IDAT", "IEND":
+			return nil
+		case "acTL":
+			data, err := readChunk(br, n)
+			if err != nil {
+				return err
+			}
+			if len(data) >= 4 {
+				info.Frames = max(1, int(binary.BigEndian.Uint32(data)))
+			}
+		case "eXIf":
+			data, err := readChunk(br, n)
+			if err != nil {
+				return err
+			}
+			info.Orientation = exifOrientation(data)
+		default:
+			if _, err := br.Discard(n); err != nil {
+				return err
+			}
+		}
+		if _, err := br.Discard(4); err != nil { // CRC
+			return err
+		}
+	}
+}
+
+// probeGIF counts the frames of a GIF, skipping over their data.
+func probeGIF(r io.Reader, info *Info) error {
+	br, err := decodeConfig(r, info)
+	if err != nil {
+		return err
+	}
+	var header [13]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return err
+	}
+	if header[10]&0x80 != 0 {
+		if _, err := br.Discard(3 << (header[10]&0x07 + 1)); err != nil {
+			return err
+		}
+	}
+	info.Frames = 0
+	for {
+		block, err := br.ReadByte()
+		if err == io.EOF && info.Frames > 0 {
+			// a missing trailer
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch block {
+		case 0x21: // extension
+			if _, err := br.Discard(1); err != nil {
+				return err
+			}
+		case 0x2c: // image descriptor
+			var desc [9]byte
+			if _, err := io.ReadFull(br, desc[:]); err != nil {
+				return err
+			}
+			if desc[8]&0x80 != 0 {
+				if _, err := br.Discard(3 << (desc[8]&0x07 + 1)); err != nil {
+					return err
+				}
+			}
+			if _, err := br.Discard(1); err != nil { // LZW minimum code size
+				return err
+			}
+			info.Frames++
+		case 0x3b: // trailer
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown GIF block 0x%02x", ErrInvalidHeader, block)
+		}
+		if err := skipSubBlocks(br); err != nil {
+			return err
+		}
+	}
+}
+
+// skipSubBlocks skips a sequence of GIF data sub-blocks up to its
+// terminator.
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil || size == 0 {
+			return err
+		}
+		if _, err := br.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// probeWebP reads the size, the number of frames and the
+// orientation from the chunks of a WebP, which the standard
+// library can't decode.
+func probeWebP(r io.Reader, info *Info) error {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(12); err != nil { // RIFF header
+		return err
+	}
+	extended, alpha := false, false
+	info.Frames = 0
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF && info.ColorModel != nil {
+				break
+			}
+			return err
+		}
+		n, err := chunkLen(binary.LittleEndian.Uint32(header[4:]))
+		if err != nil {
+			return err
+		}
+		padded := n + n&1
+		switch string(header[:4]) {
+		case "VP8X":
+			data, err := readChunk(br, padded)
+			if err != nil {
+				return err
+			}
+			if len(data) < 10 {
+				return fmt.Errorf("%w: short VP8X chunk", ErrInvalidHeader)
+			}
+			extended, alpha = true, data[0]&0x10 != 0
+			info.Width = 1 + (int(data[4]) | int(data[5])<<8 | int(data[6])<<16)
+			info.Height = 1 + (int(data[7]) | int(data[8])<<8 | int(data[9])<<16)
+			continue
+		case "ANMF":
+			info.Frames++
+			info.ColorModel = color.NRGBAModel
+		case "EXIF":
+			data, err := readChunk(br, padded)
+			if err != nil {
+				return err
+			}
+			info.Orientation = exifOrientation(data)
+			continue
+		case "VP8 ", "VP8L":
+			if info.ColorModel != nil {
+				break
+			}
+			info.Frames = 1
+			data, err := br.Peek(min(n, 10))
+			if err != nil {
+				return err
+			}
+			width, height, model, err := webPBitstream(string(header[:4]), data, alpha)
+			if err != nil {
+				return err
+			}
+			info.ColorModel = model
+			if !extended {
+				info.Width, info.Height = width, height
+				// a simple WebP has no other chunk
+				return nil
+			}
+		}
+		if _, err := br.Discard(padded); err != nil {
+			return err
+		}
+	}
+	if info.Frames == 0 {
+		return fmt.Errorf("%w: WebP without image", ErrInvalidHeader)
+	}
+	return nil
+}
+
+// webPBitstream returns the size and color model of a VP8 or VP8L
+// bitstream from its first bytes.
+func webPBitstream(format string, data []byte, alpha bool) (width, height int, model color.Model, err error) {
+	if format == "VP8L" {
+		if len(data) < 5 || data[0] != 0x2f {
+			return 0, 0, nil, fmt.Errorf("%w: invalid VP8L header", ErrInvalidHeader)
+		}
+		bits := binary.LittleEndian.Uint32(data[1:])
+		return 1 + int(bits&0x3fff), 1 + int(bits>>14&0x3fff), color.NRGBAModel, nil
+	}
+	if len(data) < 10 || data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+		return 0, 0, nil, fmt.Errorf("%w: invalid VP8 header", ErrInvalidHeader)
+	}
+	model = color.YCbCrModel
+	if alpha {
+		model = color.NYCbCrAModel
+	}
+	width = int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff)
+	height = int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff)
+	return width, height, model, nil
+}
+
+// chunkLen returns the length of a PNG or WebP chunk as an int,
+// rejecting the lengths that would overflow it on 32-bit
+// platforms once padded.
+func chunkLen(n uint32) (int, error) {
+	if n >= math.MaxInt32 {
+		return 0, fmt.Errorf("%w: chunk length %d", ErrInvalidHeader, n)
+	}
+	return int(n), nil
+}
+
+// readChunk reads the n bytes of a chunk, and returns at most the
+// first maxExifLen.
+func readChunk(br *bufio.Reader, n int) ([]byte, error) {
+	data := make([]byte, min(n, maxExifLen))
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+	_, err := br.Discard(n - len(data))
+	return data, err
+}
+
+// exifOrientation returns the orientation stored in the first IFD
+// of EXIF data, 1 when it has none.
+func exifOrientation(data []byte) int {
+	b := bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+	if len(b) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(b[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int64(order.Uint32(b[4:]))
+	if ifd+2 > int64(len(b)) {
+		return 1
+	}
+	entries := b[ifd+2:]
+	for i := 0; i < int(order.Uint16(b[ifd:])) && len(entries) >= 12; i++ {
+		entry := entries[:12]
+		entries = entries[12:]
+		const tagOrientation, typeShort = 0x0112, 3
+		if order.Uint16(entry) == tagOrientation && order.Uint16(entry[2:]) == typeShort {
+			if o := int(order.Uint16(entry[8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
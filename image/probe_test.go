@@ -0,0 +1,162 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifData returns big endian EXIF data holding orientation.
+func exifData(orientation uint16) []byte {
+	b := []byte("MM\x00*\x00\x00\x00\x08\x00\x01")
+	b = binary.BigEndian.AppendUint16(b, 0x0112)
+	b = binary.BigEndian.AppendUint16(b, 3)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint16(b, orientation)
+	return append(b, 0, 0, 0, 0, 0, 0)
+}
+
+func jpegWithExif(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	app1 := append([]byte("Exif\x00\x00"), exifData(6)...)
+	segment := append([]byte{0xff, 0xe1}, binary.BigEndian.AppendUint16(nil, uint16(len(app1)+2))...)
+	segment = append(segment, app1...)
+	b := buf.Bytes()
+	return append(append(b[:2:2], segment...), b[2:]...)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(append(b, typ...), data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+func riffChunk(typ string, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32([]byte(typ), uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func webp(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+}
+
+func TestProbe(t *testing.T) {
+	var apng bytes.Buffer
+	if err := png.Encode(&apng, image.NewGray(image.Rect(0, 0, 8, 4))); err != nil {
+		t.Fatal(err)
+	}
+	b := apng.Bytes()
+	actl := binary.BigEndian.AppendUint32(nil, 4)
+	actl = binary.BigEndian.AppendUint32(actl, 0)
+	apngBytes := append(append(append(b[:33:33], pngChunk("acTL", actl)...), pngChunk("eXIf", exifData(3))...), b[33:]...)
+
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 12, 10), palette))
+		g.Delay = append(g.Delay, 10)
+	}
+	var animated bytes.Buffer
+	if err := gif.EncodeAll(&animated, g); err != nil {
+		t.Fatal(err)
+	}
+	// without trailer
+	truncated := animated.Bytes()[:animated.Len()-1]
+
+	vp8x := []byte{0x02 | 0x08, 0, 0, 0, 0xff, 0x01, 0x00, 0xef, 0x00, 0x00} // 512x240
+	lossless := []byte{0x2f}
+	lossless = binary.LittleEndian.AppendUint32(lossless, 99|49<<14)
+	lossy := []byte{0, 0, 0, 0x9d, 0x01, 0x2a}
+	lossy = binary.LittleEndian.AppendUint16(lossy, 640)
+	lossy = binary.LittleEndian.AppendUint16(lossy, 480)
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{"jpeg", jpegWithExif(t), Info{Width: 30, Height: 20, Frames: 1, ColorModel: color.YCbCrModel, Orientation: 6}},
+		{"apng", apngBytes, Info{Width: 8, Height: 4, Frames: 4, ColorModel: color.GrayModel, Orientation: 3}},
+		{"gif", animated.Bytes(), Info{Width: 12, Height: 10, Frames: 3, Orientation: 1}},
+		{"gif without trailer", truncated, Info{Width: 12, Height: 10, Frames: 3, Orientation: 1}},
+		{"webp lossless", webp(riffChunk("VP8L", lossless)), Info{Width: 100, Height: 50, Frames: 1, ColorModel: color.NRGBAModel, Orientation: 1}},
+		{"webp lossy", webp(riffChunk("VP8 ", lossy)), Info{Width: 640, Height: 480, Frames: 1, ColorModel: color.YCbCrModel, Orientation: 1}},
+		{"webp animated", webp(riffChunk("VP8X", vp8x), riffChunk("ANIM", make([]byte, 6)), riffChunk("ANMF", make([]byte, 17)),
+			riffChunk("ANMF", make([]byte, 17)), riffChunk("EXIF", exifData(8))), Info{Width: 512, Height: 240, Frames: 2, ColorModel: color.NRGBAModel, Orientation: 8}},
+	} {
+		got, err := Probe(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Confidence != ConfidenceHigh {
+			t.Errorf("%s: sniffed %+v", tt.name, got.Sniffed)
+		}
+		if got.Width != tt.want.Width || got.Height != tt.want.Height || got.Frames != tt.want.Frames ||
+			got.Orientation != tt.want.Orientation {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+		// a GIF decodes to its palette
+		if tt.want.ColorModel == nil {
+			if _, ok := got.ColorModel.(color.Palette); !ok {
+				t.Errorf("%s: color model %T, want a palette", tt.name, got.ColorModel)
+			}
+		} else if got.ColorModel != tt.want.ColorModel {
+			t.Errorf("%s: color model %v, want %v", tt.name, got.ColorModel, tt.want.ColorModel)
+		}
+	}
+
+	if info, err := Probe(bytes.NewReader(jpegWithExif(t))); err != nil {
+		t.Fatal(err)
+	} else if w, h := info.DisplaySize(); w != 20 || h != 30 {
+		t.Errorf("display size %dx%d, want 20x30", w, h)
+	}
+}
+
+func TestProbeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 4))); err != nil {
+		t.Fatal(err)
+	}
+	// chunk lengths that are negative as 32-bit ints
+	hugePNG := append(buf.Bytes()[:33:33], "\xff\xff\xff\xffeXIfMM\x00*"...)
+	hugeWebP := webp([]byte("EXIF\xff\xff\xff\xffMM\x00*"))
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"binary", []byte{0, 1, 2, 3}, ErrUnknownType},
+		{"html", []byte("<html></html>"), ErrUnsupportedType},
+		{"pdf", []byte("%PDF-1.4\n"), ErrUnsupportedType},
+		{"truncated jpeg", jpegWithExif(t)[:40], ErrInvalidHeader},
+		{"truncated gif", []byte("GIF89a\x0c\x00"), ErrInvalidHeader},
+		{"webp without image", webp(riffChunk("VP8X", make([]byte, 10))), ErrInvalidHeader},
+		{"huge png chunk", hugePNG, ErrInvalidHeader},
+		{"huge webp chunk", hugeWebP, ErrInvalidHeader},
+	} {
+		if _, err := Probe(bytes.NewReader(tt.data)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
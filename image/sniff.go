@@ -0,0 +1,222 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/h2non/filetype"
+)
+
+// sniffLen is the number of bytes Sniff reads, enough for both
+// filetype and http.DetectContentType.
+const sniffLen = 512
+
+var (
+	// ErrUnknownType is returned when the type of the content
+	// can't be told.
+	ErrUnknownType = errors.New("image: unknown content type")
+	// ErrUnsupportedType is returned when the content is not of
+	// an allowed type.
+	ErrUnsupportedType = errors.New("image: unsupported content type")
+	// ErrTypeMismatch is returned when the content does not match
+	// the declared file name extension or Content-Type.
+	ErrTypeMismatch = errors.New("image: content type mismatch")
+)
+
+// Confidence tells how reliably the type of some content was
+// sniffed.
+type Confidence int
+
+const (
+	// ConfidenceNone means the type is unknown.
+	ConfidenceNone Confidence = iota
+	// ConfidenceLow means the type was guessed from textual
+	// content, such as HTML or SVG, which any text can imitate.
+	ConfidenceLow
+	// ConfidenceHigh means the content starts with the magic
+	// number of the type.
+	ConfidenceHigh
+)
+
+// Sniffed is the type of some content, as sniffed by Sniff.
+type Sniffed struct {
+	// MIME is the media type, without parameters, such as
+	// "image/jpeg". It is empty when the type is unknown.
+	MIME string
+	// Extension is the usual file name extension of the type,
+	// without dot, such as "jpg". It may be empty when the
+	// confidence is low.
+	Extension  string
+	Confidence Confidence
+}
+
+// Sniff reads the start of r and returns the type of its content.
+// The content is known from its magic number with a high
+// confidence, else from its text with a low confidence. Content
+// shorter than what Sniff reads is not an error.
+func Sniff(r io.Reader) (Sniffed, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Sniffed{}, err
+	}
+	return SniffBytes(head[:n]), nil
+}
+
+// SniffBytes returns the type of data, see Sniff.
+func SniffBytes(data []byte) Sniffed {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	if kind, _ := filetype.Match(data); kind != filetype.Unknown {
+		return Sniffed{MIME: kind.MIME.Value, Extension: kind.Extension, Confidence: ConfidenceHigh}
+	}
+	if len(data) == 0 {
+		return Sniffed{}
+	}
+	contentType := http.DetectContentType(data)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isSVG(data, mediaType):
+		return Sniffed{MIME: "image/svg+xml", Extension: "svg", Confidence: ConfidenceLow}
+	case mediaType == "application/octet-stream":
+		return Sniffed{}
+	}
+	return Sniffed{MIME: mediaType, Extension: textExtensions[mediaType], Confidence: ConfidenceLow}
+}
+
+// textExtensions are the extensions of the types
+// http.DetectContentType tells from text.
+var textExtensions = map[string]string{
+	"text/plain": "txt",
+	"text/html":  "html",
+	"text/xml":   "xml",
+}
+
+// isSVG reports whether data, of the given detected type, is an
+// SVG document.
+func isSVG(data []byte, mediaType string) bool {
+	if mediaType != "text/xml" && mediaType != "text/plain" {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(data), []byte("<svg"))
+}
+
+// Known reports whether the type is known.
+func (s Sniffed) Known() bool {
+	return s.Confidence != ConfidenceNone
+}
+
+// Verify checks the type against the file name and the
+// Content-Type declared with the content, as an upload does, and
+// returns ErrTypeMismatch when either contradicts it. An empty
+// file name or Content-Type, a file name without extension and
+// the generic application/octet-stream are not checked. Verify
+// returns ErrUnknownType when the type is not known.
+func (s Sniffed) Verify(filename, contentType string) error {
+	if !s.Known() {
+		return ErrUnknownType
+	}
+	if ext := strings.TrimPrefix(strings.ToLower(path.Ext(filename)), "."); ext != "" && !s.matchesExtension(ext) {
+		return fmt.Errorf("%w: %s content named %q", ErrTypeMismatch, s.MIME, filename)
+	}
+	if contentType == "" {
+		return nil
+	}
+	declared, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: invalid Content-Type %q", ErrTypeMismatch, contentType)
+	}
+	if declared != "application/octet-stream" && normalizeMIME(declared) != normalizeMIME(s.MIME) {
+		return fmt.Errorf("%w: %s content declared as %s", ErrTypeMismatch, s.MIME, declared)
+	}
+	return nil
+}
+
+// Allowed returns ErrUnsupportedType unless the extension of the
+// type is one of extensions, ErrUnknownType if the type is not
+// known.
+func (s Sniffed) Allowed(extensions ...string) error {
+	if !s.Known() {
+		return ErrUnknownType
+	}
+	for _, ext := range extensions {
+		if s.matchesExtension(strings.ToLower(strings.TrimPrefix(ext, "."))) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedType, s.MIME)
+}
+
+// extensionAliases are the other extensions of the types.
+var extensionAliases = map[string]string{
+	"jpeg": "jpg",
+	"jpe":  "jpg",
+	"jfif": "jpg",
+	"tiff": "tif",
+	"heic": "heif",
+	"htm":  "html",
+}
+
+// mimeAliases are the legacy or non standard names of the types.
+var mimeAliases = map[string]string{
+	"image/jpg":      "image/jpeg",
+	"image/pjpeg":    "image/jpeg",
+	"image/x-png":    "image/png",
+	"image/x-icon":   "image/vnd.microsoft.icon",
+	"image/heic":     "image/heif",
+	"image/x-ms-bmp": "image/bmp",
+}
+
+// matchesExtension reports whether ext, lower case and without
+// dot, is an extension of the type.
+func (s Sniffed) matchesExtension(ext string) bool {
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	if ext == s.Extension {
+		return true
+	}
+	typ, _, _ := mime.ParseMediaType(mime.TypeByExtension("." + ext))
+	return typ != "" && normalizeMIME(typ) == normalizeMIME(s.MIME)
+}
+
+func normalizeMIME(typ string) string {
+	typ = strings.ToLower(typ)
+	if alias, ok := mimeAliases[typ]; ok {
+		return alias
+	}
+	return typ
+}
+
+// SniffUpload sniffs the type of an uploaded file and verifies it
+// against the file name and Content-Type of the upload. When
+// extensions are given, the type must be one of them. Handlers
+// can reject the upload on any error, which wraps
+// ErrUnknownType, ErrUnsupportedType or ErrTypeMismatch unless
+// the file could not be read.
+func SniffUpload(file *multipart.FileHeader, extensions ...string) (Sniffed, error) {
+	src, err := file.Open()
+	if err != nil {
+		return Sniffed{}, err
+	}
+	defer src.Close()
+	s, err := Sniff(src)
+	if err != nil {
+		return s, err
+	}
+	if err := s.Verify(file.Filename, file.Header.Get("Content-Type")); err != nil {
+		return s, err
+	}
+	if len(extensions) > 0 {
+		return s, s.Allowed(extensions...)
+	}
+	return s, nil
+}
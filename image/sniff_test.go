@@ -0,0 +1,133 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+	"testing/iotest"
+)
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want Sniffed
+	}{
+		{"png", pngBytes(t), Sniffed{"image/png", "png", ConfidenceHigh}},
+		{"gif", []byte("GIF89a"), Sniffed{"image/gif", "gif", ConfidenceHigh}},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`), Sniffed{"image/svg+xml", "svg", ConfidenceLow}},
+		{"html", []byte("<html><body>hi</body></html>"), Sniffed{"text/html", "html", ConfidenceLow}},
+		{"binary", []byte{0xff, 0xd8, 0x00, 0x01, 0x02}, Sniffed{}},
+		{"empty", nil, Sniffed{}},
+	} {
+		got, err := Sniff(iotest.OneByteReader(bytes.NewReader(tt.data)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	errRead := errors.New("read")
+	if _, err := Sniff(iotest.ErrReader(errRead)); !errors.Is(err, errRead) {
+		t.Errorf("read error %v, want %v", err, errRead)
+	}
+}
+
+func TestCheckExtensionWithBytes(t *testing.T) {
+	for data, want := range map[string]string{
+		"":                  "jpg",
+		"\xff\xd8":          "jpg",
+		"GIF89a":            "gif",
+		"<svg></svg>":       "jpg",
+		string(pngBytes(t)): "png",
+	} {
+		if got, err := CheckExtensionWithBytes([]byte(data)); err != nil || got != want {
+			t.Errorf("%q: %q, %v, want %q", data, got, err, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := SniffBytes(pngBytes(t))
+	for _, tt := range []struct {
+		filename, contentType string
+		err                   error
+	}{
+		{"a.png", "image/png", nil},
+		{"A.PNG", "image/x-png", nil},
+		{"", "", nil},
+		{"upload", "application/octet-stream", nil},
+		{"a.jpg", "image/png", ErrTypeMismatch},
+		{"a.png", "image/jpeg", ErrTypeMismatch},
+		{"a.png", "image/png; charset=", ErrTypeMismatch},
+	} {
+		if err := s.Verify(tt.filename, tt.contentType); !errors.Is(err, tt.err) {
+			t.Errorf("%q %q: %v, want %v", tt.filename, tt.contentType, err, tt.err)
+		}
+	}
+
+	jpeg := Sniffed{"image/jpeg", "jpg", ConfidenceHigh}
+	if err := jpeg.Verify("photo.JPEG", "image/jpg"); err != nil {
+		t.Errorf("jpeg aliases: %v", err)
+	}
+	if err := (Sniffed{}).Verify("a.png", "image/png"); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("unknown type: %v", err)
+	}
+	if err := jpeg.Allowed("png", ".jpeg"); err != nil {
+		t.Errorf("allowed: %v", err)
+	}
+	if err := jpeg.Allowed("png", "gif"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("not allowed: %v", err)
+	}
+}
+
+func TestSniffUpload(t *testing.T) {
+	upload := func(filename, contentType string, data []byte) *multipart.FileHeader {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="file"; filename="`+filename+`"`)
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+		w.Close()
+		form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return form.File["file"][0]
+	}
+
+	s, err := SniffUpload(upload("a.png", "image/png", pngBytes(t)), "jpg", "png")
+	if err != nil || s.Extension != "png" {
+		t.Errorf("png upload: %+v, %v", s, err)
+	}
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	if _, err := SniffUpload(upload("a.png", "image/png", svg), "png"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("spoofed upload: %v", err)
+	}
+	if _, err := SniffUpload(upload("a.svg", "image/svg+xml", svg), "png", "jpg"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("unsupported upload: %v", err)
+	}
+	if ext, err := CheckExtension(upload("a.png", "image/png", pngBytes(t))); err != nil || ext != "png" {
+		t.Errorf("CheckExtension: %q, %v", ext, err)
+	}
+}